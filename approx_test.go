@@ -0,0 +1,174 @@
+package blurhash_test
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math/rand"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+// photoHashes returns hashes of random crops of the test fixtures at
+// random component counts, as a stand-in for hashes of photographs.
+func photoHashes(t *testing.T, perFixture int) []string {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	var hashes []string
+	for _, test := range testFixtures {
+		if test.file == "" {
+			continue
+		}
+		img := loadNRGBA(t, test.file)
+		b := img.Bounds()
+		for n := 0; n < perFixture; n++ {
+			w, h := 8+rng.Intn(b.Dx()-8), 8+rng.Intn(b.Dy()-8)
+			x, y := b.Min.X+rng.Intn(b.Dx()-w+1), b.Min.Y+rng.Intn(b.Dy()-h+1)
+			hash, err := blurhash.Encode(rng.Intn(9)+1, rng.Intn(9)+1, img.SubImage(image.Rect(x, y, x+w, y+h)))
+			if err != nil {
+				t.Fatalf("error encoding: %v", err)
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+	return hashes
+}
+
+func TestApproximation(t *testing.T) {
+	hashes := photoHashes(t, 8)
+	tests := []struct {
+		approx  blurhash.Approximation
+		maxDiff int
+	}{
+		// The documented maximum errors.
+		{blurhash.Approximation{Interpolation: blurhash.InterpolateBilinear}, 20},
+		{blurhash.Approximation{Interpolation: blurhash.InterpolateBicubic}, 8},
+		{blurhash.Approximation{Interpolation: blurhash.InterpolateBilinear, SRGB: true}, 37},
+		{blurhash.Approximation{Interpolation: blurhash.InterpolateBicubic, SRGB: true}, 35},
+	}
+	for _, size := range []image.Point{{300, 17}, {128, 96}} {
+		for _, hash := range hashes {
+			want := image.NewNRGBA(image.Rect(0, 0, size.X, size.Y))
+			if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+			for _, test := range tests {
+				dec := blurhash.Decoder{Approximation: test.approx}
+				got := image.NewNRGBA(want.Rect)
+				if err := dec.DecodeDraw(got, hash, 1); err != nil {
+					t.Fatalf("error decoding: %v", err)
+				}
+				for i := range got.Pix {
+					if d := absDiff(got.Pix[i], want.Pix[i]); d > test.maxDiff {
+						t.Fatalf("%s %+v at %v: channel %d differs by %d, want at most %d", hash, test.approx, size, i, d, test.maxDiff)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestApproximationSmallImage(t *testing.T) {
+	// A 4x3 hash needs a 25x17 grid by default, so smaller images are
+	// decoded exactly.
+	hash := testFixtures[0].hash
+	want := image.NewNRGBA(image.Rect(0, 0, 25, 17))
+	if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	dec := blurhash.Decoder{Approximation: blurhash.Approximation{Interpolation: blurhash.InterpolateBicubic}}
+	got := image.NewNRGBA(want.Rect)
+	if err := dec.DecodeDraw(got, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if string(got.Pix) != string(want.Pix) {
+		t.Error("image no larger than the grid was not decoded exactly")
+	}
+}
+
+func TestApproximationOutputs(t *testing.T) {
+	hash := testFixtures[2].hash
+	r := image.Rect(0, 0, 200, 150)
+	for _, approx := range []blurhash.Approximation{
+		{Interpolation: blurhash.InterpolateBilinear, Samples: 3},
+		{Interpolation: blurhash.InterpolateBicubic, SRGB: true},
+	} {
+		dec := blurhash.Decoder{Approximation: approx}
+		want := image.NewNRGBA(r)
+		if err := dec.DecodeDraw(want, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+
+		// Parallel decoding is identical.
+		dec.Workers = 3
+		parallel := image.NewNRGBA(r)
+		if err := dec.DecodeDraw(parallel, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		if string(parallel.Pix) != string(want.Pix) {
+			t.Errorf("%+v: parallel decode differs", approx)
+		}
+
+		// 16-bit and dithered output interpolate the same values.
+		dec.HighBitDepth = true
+		wide := image.NewNRGBA64(r)
+		if err := dec.DecodeDraw(wide, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		dec.Dither = blurhash.DitherBayer
+		dithered := image.NewNRGBA(r)
+		if err := dec.DecodeDraw(dithered, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		for i := range want.Pix {
+			if d := absDiff(uint8((int(wide.Pix[i*2])<<8|int(wide.Pix[i*2+1])+128)/257), want.Pix[i]); d > 1 {
+				t.Fatalf("%+v: 16-bit channel %d differs from 8-bit by %d", approx, i, d)
+			}
+			if d := absDiff(dithered.Pix[i], want.Pix[i]); d > 1 {
+				t.Fatalf("%+v: dithered channel %d differs by %d", approx, i, d)
+			}
+		}
+	}
+}
+
+func TestApproximationInvalid(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for _, approx := range []blurhash.Approximation{
+		{Interpolation: -1},
+		{Interpolation: blurhash.InterpolateBicubic + 1},
+		{Interpolation: blurhash.InterpolateBilinear, Samples: -1},
+	} {
+		dec := blurhash.Decoder{Approximation: approx}
+		if err := dec.DecodeDraw(img, testFixtures[0].hash, 1); !errors.Is(err, blurhash.ErrInvalidApproximation) {
+			t.Errorf("%+v: expected %v, got %v", approx, blurhash.ErrInvalidApproximation, err)
+		}
+	}
+}
+
+func BenchmarkDecodeApproximate(b *testing.B) {
+	modes := []blurhash.Approximation{
+		{},
+		{Interpolation: blurhash.InterpolateBilinear},
+		{Interpolation: blurhash.InterpolateBicubic},
+		{Interpolation: blurhash.InterpolateBilinear, SRGB: true},
+	}
+	for _, hash := range []string{testFixtures[0].hash, testFixtures[2].hash} {
+		for _, size := range []int{128, 512} {
+			for _, approx := range modes {
+				name := fmt.Sprintf("%s/%d/%v", hash[:6], size, approx.Interpolation)
+				if approx.SRGB {
+					name += "-srgb"
+				}
+				b.Run(name, func(b *testing.B) {
+					dec := blurhash.Decoder{Approximation: approx}
+					img := image.NewNRGBA(image.Rect(0, 0, size, size))
+					b.ResetTimer()
+					for i := 0; i < b.N; i++ {
+						_ = dec.DecodeDraw(img, hash, 1)
+					}
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package blurhash
+
+import (
+	"fmt"
+	"math"
+)
+
+// PixelFormat is the memory layout of pixels in a raw buffer, such as a
+// texture, framebuffer or captured video frame, decoded into by
+// DecodeBuffer or encoded by EncodeBuffer.
+//
+// Decoded placeholders are opaque, so premultiplied formats hold the same
+// bytes as their straight counterparts. They are provided so callers can
+// name the format their renderer declares.
+type PixelFormat int
+
+const (
+	// PixelRGBA8 is four bytes per pixel in the order red, green, blue,
+	// alpha, as in image.NRGBA.
+	PixelRGBA8 PixelFormat = iota
+
+	// PixelBGRA8 is four bytes per pixel in the order blue, green, red,
+	// alpha, common in GPU textures and Windows bitmaps.
+	PixelBGRA8
+
+	// PixelRGBA8Premultiplied is PixelRGBA8 with premultiplied alpha, as
+	// in image.RGBA.
+	PixelRGBA8Premultiplied
+
+	// PixelBGRA8Premultiplied is PixelBGRA8 with premultiplied alpha.
+	PixelBGRA8Premultiplied
+
+	// PixelRGB888 is three bytes per pixel in the order red, green, blue.
+	PixelRGB888
+
+	// PixelRGB565 is two bytes per pixel holding a little-endian 16-bit
+	// value with 5 bits of red in the high bits, 6 of green and 5 of
+	// blue, common in embedded framebuffers.
+	PixelRGB565
+
+	// PixelARGB8 is four bytes per pixel in the order alpha, red, green,
+	// blue.
+	PixelARGB8
+
+	// PixelBGR888 is three bytes per pixel in the order blue, green, red,
+	// as in Windows 24-bit bitmaps.
+	PixelBGR888
+
+	// PixelI420 is planar YUV 4:2:0: a plane of one luma byte per pixel
+	// with rows stride bytes apart, followed by a Cb and then a Cr plane
+	// of (height+1)/2 rows of (width+1)/2 bytes, with rows (stride+1)/2
+	// bytes apart. It can be encoded but not decoded into.
+	PixelI420
+)
+
+// String returns the name of the pixel format.
+func (f PixelFormat) String() string {
+	switch f {
+	case PixelRGBA8:
+		return "RGBA8"
+	case PixelBGRA8:
+		return "BGRA8"
+	case PixelRGBA8Premultiplied:
+		return "RGBA8Premultiplied"
+	case PixelBGRA8Premultiplied:
+		return "BGRA8Premultiplied"
+	case PixelRGB888:
+		return "RGB888"
+	case PixelRGB565:
+		return "RGB565"
+	case PixelARGB8:
+		return "ARGB8"
+	case PixelBGR888:
+		return "BGR888"
+	case PixelI420:
+		return "I420"
+	}
+	return fmt.Sprintf("PixelFormat(%d)", int(f))
+}
+
+// BytesPerPixel returns the size of one pixel in the format, or zero if
+// the format is planar or unknown.
+func (f PixelFormat) BytesPerPixel() int {
+	switch f {
+	case PixelRGBA8, PixelBGRA8, PixelRGBA8Premultiplied, PixelBGRA8Premultiplied, PixelARGB8:
+		return 4
+	case PixelRGB888, PixelBGR888:
+		return 3
+	case PixelRGB565:
+		return 2
+	}
+	return 0
+}
+
+// DecodeBuffer decodes a blurhash directly into buf, an image of the
+// given size in the given packed pixel format whose rows start stride
+// bytes apart. A stride of zero means rows are tightly packed. Bytes
+// between the end of one row and the start of the next are left
+// untouched.
+// Internal buffers are reused across calls when possible.
+func (d *Decoder) DecodeBuffer(buf []byte, format PixelFormat, width, height, stride int, hash string, punch float64) error {
+	if format == PixelI420 {
+		return fmt.Errorf("%w: cannot decode into %v", ErrInvalidPixelFormat, format)
+	}
+	stride, err := checkBuffer(buf, format, width, height, stride)
+	if err != nil {
+		return err
+	}
+
+	numX, numY, err := d.prepare(hash, width, height, punch)
+	if err != nil {
+		return err
+	}
+
+	workers := d.Workers
+	if d.Dither.sequential() {
+		workers = 1
+	}
+	d.parallelRows(workers, height, nil, func(w *rowWorker, y0, y1 int) {
+		w.reset(width, numX, d.Dither, false, false)
+		for y := y0; y < y1; y++ {
+			d.decodeRow(w, y, width, height, numX, numY)
+			writeBufferRow(buf[y*stride:], format, w.row)
+		}
+	})
+
+	return nil
+}
+
+// writeBufferRow writes row, three sRGB bytes per pixel, into pix in the
+// given format.
+func writeBufferRow(pix []byte, format PixelFormat, row []uint8) {
+	width := len(row) / 3
+	switch format {
+	case PixelRGBA8, PixelRGBA8Premultiplied:
+		writeRow8(pix, row)
+	case PixelBGRA8, PixelBGRA8Premultiplied:
+		for i := 0; i < width; i++ {
+			pix[i*4] = row[i*3+2]
+			pix[i*4+1] = row[i*3+1]
+			pix[i*4+2] = row[i*3]
+			pix[i*4+3] = 255
+		}
+	case PixelARGB8:
+		for i := 0; i < width; i++ {
+			pix[i*4] = 255
+			copy(pix[i*4+1:i*4+4], row[i*3:i*3+3])
+		}
+	case PixelRGB888:
+		copy(pix, row)
+	case PixelBGR888:
+		for i := 0; i < width; i++ {
+			pix[i*3] = row[i*3+2]
+			pix[i*3+1] = row[i*3+1]
+			pix[i*3+2] = row[i*3]
+		}
+	case PixelRGB565:
+		for i := 0; i < width; i++ {
+			v := uint16(scaleLevel(row[i*3], 31))<<11 |
+				uint16(scaleLevel(row[i*3+1], 63))<<5 |
+				uint16(scaleLevel(row[i*3+2], 31))
+			pix[i*2] = uint8(v)
+			pix[i*2+1] = uint8(v >> 8)
+		}
+	}
+}
+
+// checkBuffer returns an error unless buf can hold an image of the given
+// size and format with rows stride bytes apart, and returns the stride
+// with zero resolved to tightly packed rows.
+func checkBuffer(buf []byte, format PixelFormat, width, height, stride int) (int, error) {
+	bpp := format.BytesPerPixel()
+	if format == PixelI420 {
+		bpp = 1
+	}
+	if bpp == 0 {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidPixelFormat, format)
+	}
+	// Images of up to 4 bytes per pixel must be addressable, as EncodeBuffer
+	// converts some formats to RGB first.
+	if width <= 0 || height <= 0 || width > math.MaxInt/4/height {
+		return 0, fmt.Errorf("%w: had width=%d, height=%d", ErrInvalidDimensions, width, height)
+	}
+	if stride == 0 {
+		stride = width * bpp
+	}
+	if stride < width*bpp {
+		return 0, fmt.Errorf("%w: stride %d is shorter than a row of %d bytes", ErrInvalidDimensions, stride, width*bpp)
+	}
+
+	// Bound the luma plane so that no offset into buf overflows; sizes
+	// beyond it could never fit in buf. The chroma planes of I420 take at
+	// most as many bytes again, and the bound leaves room for them.
+	planes := 1
+	if format == PixelI420 {
+		planes = 4
+	}
+	if height > math.MaxInt/planes/stride {
+		return 0, fmt.Errorf("%w: buffer of %d bytes is shorter than %d rows of %d bytes", ErrInvalidDimensions, len(buf), height, stride)
+	}
+	need := (height-1)*stride + width*bpp
+	if format == PixelI420 {
+		_, cr, cStride := i420Planes(height, stride)
+		need = cr + ((height+1)/2-1)*cStride + (width+1)/2
+	}
+	if len(buf) < need {
+		return 0, fmt.Errorf("%w: buffer of %d bytes is shorter than the %d required", ErrInvalidDimensions, len(buf), need)
+	}
+	return stride, nil
+}
+
+// scaleLevel rescales an 8-bit level to [0, top], rounding to nearest.
+func scaleLevel(v uint8, top int) int {
+	return (int(v)*top + 127) / 255
+}
+
+// EncodeBuffer returns the blurhash of buf, an image of the given size
+// whose rows start stride bytes apart, or whose luma rows do for planar
+// formats. A stride of zero means rows are tightly packed. Packed 8-bit
+// formats are read in place; RGB565 and planar formats are converted to
+// 8-bit RGB in a reused internal buffer first. As with Encode, alpha is
+// ignored.
+func (e *Encoder) EncodeBuffer(xComponents, yComponents int, buf []byte, format BufferFormat, width, height, stride int) (string, error) {
+	if err := checkComponents(xComponents, yComponents); err != nil {
+		return "", err
+	}
+	if err := e.Profile.check(); err != nil {
+		return "", err
+	}
+	if err := format.check(); err != nil {
+		return "", err
+	}
+	stride, err := checkBuffer(buf, format.Pixel, width, height, stride)
+	if err != nil {
+		return "", err
+	}
+
+	pix, layout := buf, layoutRGBA
+	switch format.Pixel {
+	case PixelBGRA8, PixelBGRA8Premultiplied:
+		layout = packedLayout{size: 4, r: 2, g: 1, b: 0}
+	case PixelARGB8:
+		layout = packedLayout{size: 4, r: 1, g: 2, b: 3}
+	case PixelRGB888:
+		layout = layoutRGB
+	case PixelBGR888:
+		layout = packedLayout{size: 3, r: 2, g: 1, b: 0}
+	case PixelRGB565:
+		e.rgb = growTo(e.rgb, width*height*3)
+		for y := 0; y < height; y++ {
+			unpackRGB565(e.rgb[y*width*3:(y+1)*width*3], buf[y*stride:])
+		}
+		pix, stride, layout = e.rgb, width*3, layoutRGB
+	case PixelI420:
+		e.convertI420(buf, format, width, height, stride)
+		pix, stride, layout = e.rgb, width*3, layoutRGB
+	}
+
+	e.computePackedFactors(xComponents, yComponents, pix, stride, layout, width, height, e.Optimise.rendered())
+	return e.writeQuantised(e.quantiseOptimised(xComponents, yComponents, e.factors))
+}
+
+// unpackRGB565 expands the little-endian RGB565 pixels of src into rgb,
+// three bytes per pixel.
+func unpackRGB565(rgb, src []byte) {
+	for i := 0; i < len(rgb)/3; i++ {
+		v := int(src[i*2]) | int(src[i*2+1])<<8
+		rgb[i*3] = uint8(((v>>11)*255 + 15) / 31)
+		rgb[i*3+1] = uint8(((v>>5&63)*255 + 31) / 63)
+		rgb[i*3+2] = uint8(((v&31)*255 + 15) / 31)
+	}
+}
+
+// EncodeBuffer returns the blurhash of the given buffer.
+func EncodeBuffer(xComponents, yComponents int, buf []byte, format BufferFormat, width, height, stride int) (string, error) {
+	var e Encoder
+	return e.EncodeBuffer(xComponents, yComponents, buf, format, width, height, stride)
+}
+
+// DecodeBuffer decodes the given hash directly into the given buffer.
+func DecodeBuffer(buf []byte, format PixelFormat, width, height, stride int, hash string, punch float64) error {
+	var d Decoder
+	return d.DecodeBuffer(buf, format, width, height, stride, hash, punch)
+}
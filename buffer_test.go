@@ -0,0 +1,389 @@
+package blurhash_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestDecodeBuffer(t *testing.T) {
+	const width, height, padding, sentinel = 29, 17, 5, 0xa5
+	hash := testFixtures[0].hash
+	want := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	// pixel returns the expected bytes of a pixel of want in format f.
+	pixel := func(f blurhash.PixelFormat, x, y int) []byte {
+		c := want.NRGBAAt(x, y)
+		switch f {
+		case blurhash.PixelBGRA8, blurhash.PixelBGRA8Premultiplied:
+			return []byte{c.B, c.G, c.R, c.A}
+		case blurhash.PixelARGB8:
+			return []byte{c.A, c.R, c.G, c.B}
+		case blurhash.PixelRGB888:
+			return []byte{c.R, c.G, c.B}
+		case blurhash.PixelBGR888:
+			return []byte{c.B, c.G, c.R}
+		case blurhash.PixelRGB565:
+			v := uint16((int(c.R)*31+127)/255)<<11 | uint16((int(c.G)*63+127)/255)<<5 | uint16((int(c.B)*31+127)/255)
+			return []byte{uint8(v), uint8(v >> 8)}
+		}
+		return []byte{c.R, c.G, c.B, c.A}
+	}
+
+	formats := []blurhash.PixelFormat{
+		blurhash.PixelRGBA8,
+		blurhash.PixelBGRA8,
+		blurhash.PixelRGBA8Premultiplied,
+		blurhash.PixelBGRA8Premultiplied,
+		blurhash.PixelRGB888,
+		blurhash.PixelRGB565,
+		blurhash.PixelARGB8,
+		blurhash.PixelBGR888,
+	}
+	for _, f := range formats {
+		bpp := f.BytesPerPixel()
+		for _, stride := range []int{0, width*bpp + padding} {
+			rowBytes := stride
+			if rowBytes == 0 {
+				rowBytes = width * bpp
+			}
+			// Leave the last row unpadded, as a caller slicing a larger
+			// buffer might.
+			buf := make([]byte, (height-1)*rowBytes+width*bpp)
+			for i := range buf {
+				buf[i] = sentinel
+			}
+			dec := blurhash.Decoder{Workers: 3}
+			if err := dec.DecodeBuffer(buf, f, width, height, stride, hash, 1); err != nil {
+				t.Fatalf("%v: error decoding: %v", f, err)
+			}
+
+			for y := 0; y < height; y++ {
+				row := buf[y*rowBytes:]
+				for x := 0; x < width; x++ {
+					if got, w := row[x*bpp:x*bpp+bpp], pixel(f, x, y); string(got) != string(w) {
+						t.Fatalf("%v stride=%d: pixel (%d, %d) = %v, want %v", f, stride, x, y, got, w)
+					}
+				}
+				if y < height-1 {
+					for i := width * bpp; i < rowBytes; i++ {
+						if row[i] != sentinel {
+							t.Fatalf("%v stride=%d: padding byte %d of row %d was overwritten", f, stride, i, y)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeBufferInvalid(t *testing.T) {
+	hash := testFixtures[0].hash
+	tests := []struct {
+		name                  string
+		size                  int
+		format                blurhash.PixelFormat
+		width, height, stride int
+		err                   error
+	}{
+		{"unknown format", 64, blurhash.PixelFormat(99), 4, 4, 0, blurhash.ErrInvalidPixelFormat},
+		{"planar format", 64, blurhash.PixelI420, 4, 4, 0, blurhash.ErrInvalidPixelFormat},
+		{"zero width", 64, blurhash.PixelRGBA8, 0, 4, 0, blurhash.ErrInvalidDimensions},
+		{"short stride", 64, blurhash.PixelRGBA8, 4, 4, 15, blurhash.ErrInvalidDimensions},
+		{"negative stride", 64, blurhash.PixelRGBA8, 4, 4, -16, blurhash.ErrInvalidDimensions},
+		{"short buffer", 63, blurhash.PixelRGBA8, 4, 4, 0, blurhash.ErrInvalidDimensions},
+		{"short padded buffer", 64, blurhash.PixelRGBA8, 4, 4, 20, blurhash.ErrInvalidDimensions},
+		{"overflowing size", 64, blurhash.PixelRGBA8, math.MaxInt / 2, 4, 0, blurhash.ErrInvalidDimensions},
+		{"overflowing stride", 64, blurhash.PixelRGBA8, 4, 3, math.MaxInt / 2, blurhash.ErrInvalidDimensions},
+	}
+	for _, test := range tests {
+		err := blurhash.DecodeBuffer(make([]byte, test.size), test.format, test.width, test.height, test.stride, hash, 1)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	// The smallest valid padded buffer omits the last row's padding.
+	if err := blurhash.DecodeBuffer(make([]byte, 3*20+16), blurhash.PixelRGBA8, 4, 4, 20, hash, 1); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// packedFormats are the packed pixel formats with a byte per channel.
+var packedFormats = []struct {
+	format blurhash.PixelFormat
+	order  string
+}{
+	{blurhash.PixelRGBA8, "RGBA"},
+	{blurhash.PixelBGRA8, "BGRA"},
+	{blurhash.PixelRGBA8Premultiplied, "RGBA"},
+	{blurhash.PixelBGRA8Premultiplied, "BGRA"},
+	{blurhash.PixelARGB8, "ARGB"},
+	{blurhash.PixelRGB888, "RGB"},
+	{blurhash.PixelBGR888, "BGR"},
+}
+
+// packBuffer lays out the pixels of img in a buffer with the given byte
+// order per pixel and rows stride bytes apart.
+func packBuffer(img *image.NRGBA, order string, stride int) []byte {
+	b := img.Bounds()
+	buf := make([]byte, b.Dy()*stride)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			c := img.NRGBAAt(b.Min.X+x, b.Min.Y+y)
+			for i, ch := range order {
+				v := map[rune]uint8{'R': c.R, 'G': c.G, 'B': c.B, 'A': c.A}[ch]
+				buf[y*stride+x*len(order)+i] = v
+			}
+		}
+	}
+	return buf
+}
+
+func TestEncodeBuffer(t *testing.T) {
+	img := loadNRGBA(t, testFixtures[0].file)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	for _, opt := range []blurhash.Optimisation{blurhash.OptimiseNone, blurhash.OptimiseSRGB} {
+		enc := blurhash.Encoder{Optimise: opt}
+		want, err := enc.Encode(4, 3, img)
+		if err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+
+		for _, f := range packedFormats {
+			// Packed formats are read in place, so encode exactly as
+			// the equivalent image does.
+			stride := width*len(f.order) + 3
+			buf := packBuffer(img, f.order, stride)
+			got, err := enc.EncodeBuffer(4, 3, buf, blurhash.BufferFormat{Pixel: f.format}, width, height, stride)
+			if err != nil {
+				t.Fatalf("%v: error encoding: %v", f.format, err)
+			}
+			if got != want {
+				t.Errorf("%v %v: got %q, want %q", opt, f.format, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeBufferRGB565(t *testing.T) {
+	img := loadNRGBA(t, testFixtures[1].file)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+
+	// Reduce the image to the levels RGB565 can represent.
+	buf := make([]byte, width*height*2)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(x, y)
+			r, g, b := int(c.R)>>3, int(c.G)>>2, int(c.B)>>3
+			v := r<<11 | g<<5 | b
+			buf[(y*width+x)*2], buf[(y*width+x)*2+1] = uint8(v), uint8(v>>8)
+			img.SetNRGBA(x, y, color.NRGBA{uint8((r*255 + 15) / 31), uint8((g*255 + 31) / 63), uint8((b*255 + 15) / 31), 255})
+		}
+	}
+
+	want, err := blurhash.Encode(4, 3, img)
+	if err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	got, err := blurhash.EncodeBuffer(4, 3, buf, blurhash.BufferFormat{Pixel: blurhash.PixelRGB565}, width, height, 0)
+	if err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// i420Buffer converts img to I420 in the given format. Chroma is
+// averaged over the luma pixels nearest each chroma sample's siting.
+func i420Buffer(img *image.NRGBA, format blurhash.BufferFormat, stride int) []byte {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	cw, ch, cStride := (width+1)/2, (height+1)/2, (stride+1)/2
+	buf := make([]byte, stride*height+2*cStride*ch)
+	cb, cr := buf[stride*height:], buf[stride*height+cStride*ch:]
+
+	kr, kb := 0.299, 0.114
+	if format.Matrix == blurhash.MatrixBT709 {
+		kr, kb = 0.2126, 0.0722
+	}
+	yuv := func(x, y int) (float64, float64, float64) {
+		c := img.NRGBAAt(x, y)
+		l := kr*float64(c.R) + (1-kr-kb)*float64(c.G) + kb*float64(c.B)
+		u := (float64(c.B) - l) / (2 * (1 - kb))
+		v := (float64(c.R) - l) / (2 * (1 - kr))
+		if format.FullRange {
+			return l, u + 128, v + 128
+		}
+		return l*219/255 + 16, u*224/255 + 128, v*224/255 + 128
+	}
+	level := func(v float64) uint8 {
+		return uint8(math.Max(0, math.Min(255, math.Round(v))))
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			l, _, _ := yuv(x, y)
+			buf[y*stride+x] = level(l)
+		}
+	}
+	for j := 0; j < ch; j++ {
+		for i := 0; i < cw; i++ {
+			xs, ys := []int{2 * i, 2*i + 1}, []int{2 * j, 2*j + 1}
+			if format.ChromaSiting != blurhash.ChromaCentre {
+				xs = xs[:1]
+			}
+			if format.ChromaSiting == blurhash.ChromaTopLeft {
+				ys = ys[:1]
+			}
+			var su, sv, n float64
+			for _, y := range ys {
+				for _, x := range xs {
+					if x < width && y < height {
+						_, u, v := yuv(x, y)
+						su, sv, n = su+u, sv+v, n+1
+					}
+				}
+			}
+			cb[j*cStride+i], cr[j*cStride+i] = level(su/n), level(sv/n)
+		}
+	}
+	return buf
+}
+
+// meanRenderDiff returns the mean difference between the channels of
+// two hashes decoded at the same size.
+func meanRenderDiff(t *testing.T, a, b string) float64 {
+	t.Helper()
+	imgA := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	imgB := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	if err := blurhash.DecodeDraw(imgA, a, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if err := blurhash.DecodeDraw(imgB, b, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	sum := 0
+	for i := range imgA.Pix {
+		sum += absDiff(imgA.Pix[i], imgB.Pix[i])
+	}
+	return float64(sum) / float64(len(imgA.Pix))
+}
+
+func TestEncodeBufferI420(t *testing.T) {
+	for _, test := range testFixtures[:2] {
+		img := loadNRGBA(t, test.file)
+		width, height := img.Bounds().Dx(), img.Bounds().Dy()
+		want, err := blurhash.Encode(test.xComp, test.yComp, img)
+		if err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+
+		for _, siting := range []blurhash.ChromaSiting{blurhash.ChromaCentre, blurhash.ChromaLeft, blurhash.ChromaTopLeft} {
+			for _, matrix := range []blurhash.YCbCrMatrix{blurhash.MatrixBT601, blurhash.MatrixBT709} {
+				for _, full := range []bool{false, true} {
+					format := blurhash.BufferFormat{Pixel: blurhash.PixelI420, ChromaSiting: siting, Matrix: matrix, FullRange: full}
+					stride := width + 5
+					got, err := blurhash.EncodeBuffer(test.xComp, test.yComp, i420Buffer(img, format, stride), format, width, height, stride)
+					if err != nil {
+						t.Fatalf("%+v: error encoding: %v", format, err)
+					}
+					// Subsampled chroma and rounded samples shift the
+					// factors slightly, so compare renders.
+					d := meanRenderDiff(t, got, want)
+					if d > 2 {
+						t.Errorf("%s %+v: got %q, want about %q, differing by %.2f on average", test.file, format, got, want, d)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeBufferInvalid(t *testing.T) {
+	i420 := blurhash.BufferFormat{Pixel: blurhash.PixelI420}
+	tests := []struct {
+		name                  string
+		size                  int
+		format                blurhash.BufferFormat
+		width, height, stride int
+		err                   error
+	}{
+		{"unknown format", 64, blurhash.BufferFormat{Pixel: blurhash.PixelFormat(99)}, 4, 4, 0, blurhash.ErrInvalidPixelFormat},
+		{"unknown siting", 64, blurhash.BufferFormat{ChromaSiting: 3}, 4, 4, 0, blurhash.ErrInvalidPixelFormat},
+		{"unknown matrix", 64, blurhash.BufferFormat{Matrix: -1}, 4, 4, 0, blurhash.ErrInvalidPixelFormat},
+		{"short buffer", 63, blurhash.BufferFormat{}, 4, 4, 0, blurhash.ErrInvalidDimensions},
+		{"zero height", 64, i420, 4, 0, 0, blurhash.ErrInvalidDimensions},
+		{"short I420 stride", 64, i420, 5, 3, 4, blurhash.ErrInvalidDimensions},
+		// 5x3 I420 needs 15 luma bytes and 2 rows of 3 bytes per chroma
+		// plane, the last row of Cr unpadded.
+		{"short I420 buffer", 26, i420, 5, 3, 0, blurhash.ErrInvalidDimensions},
+		{"short padded I420 buffer", 35, i420, 5, 3, 7, blurhash.ErrInvalidDimensions},
+		{"overflowing stride", 64, blurhash.BufferFormat{}, 4, 3, math.MaxInt / 2, blurhash.ErrInvalidDimensions},
+		{"overflowing I420 planes", 64, i420, 4, 3, math.MaxInt / 4, blurhash.ErrInvalidDimensions},
+	}
+	for _, test := range tests {
+		_, err := blurhash.EncodeBuffer(4, 3, make([]byte, test.size), test.format, test.width, test.height, test.stride)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.err, err)
+		}
+	}
+
+	for _, test := range []struct {
+		size, stride int
+	}{{27, 0}, {36, 7}} {
+		if _, err := blurhash.EncodeBuffer(4, 3, make([]byte, test.size), i420, 5, 3, test.stride); err != nil {
+			t.Errorf("stride %d: unexpected error: %v", test.stride, err)
+		}
+	}
+}
+
+func BenchmarkEncodeBuffer(b *testing.B) {
+	img := blurhash.LoadFixture(b, testFixtures[1].file).(*image.NRGBA)
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	bgra := packBuffer(img, "BGRA", width*4)
+	i420 := blurhash.BufferFormat{Pixel: blurhash.PixelI420}
+	yuv := i420Buffer(img, i420, width)
+
+	b.Run("BGRA8", func(b *testing.B) {
+		enc := blurhash.NewEncoder()
+		for i := 0; i < b.N; i++ {
+			_, _ = enc.EncodeBuffer(4, 3, bgra, blurhash.BufferFormat{Pixel: blurhash.PixelBGRA8}, width, height, 0)
+		}
+	})
+	b.Run("I420", func(b *testing.B) {
+		enc := blurhash.NewEncoder()
+		for i := 0; i < b.N; i++ {
+			_, _ = enc.EncodeBuffer(4, 3, yuv, i420, width, height, 0)
+		}
+	})
+	b.Run("Image", func(b *testing.B) {
+		enc := blurhash.NewEncoder()
+		// Hide the concrete type, as wrapping a raw buffer would.
+		src := struct{ image.Image }{img}
+		for i := 0; i < b.N; i++ {
+			_, _ = enc.Encode(4, 3, src)
+		}
+	})
+}
+
+func BenchmarkDecodeBuffer(b *testing.B) {
+	hash := testFixtures[0].hash
+	for _, f := range []blurhash.PixelFormat{blurhash.PixelBGRA8, blurhash.PixelRGB565} {
+		b.Run(f.String(), func(b *testing.B) {
+			dec := blurhash.NewDecoder()
+			buf := make([]byte, 32*32*f.BytesPerPixel())
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = dec.DecodeBuffer(buf, f, 32, 32, 0, hash, 1)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package blurhash
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"sync"
+)
+
+// DefaultCacheBytes is the memory bound of a Cache whose MaxBytes is zero.
+const DefaultCacheBytes = 64 << 20
+
+// Cache is an LRU cache of decoded placeholders keyed by hash, size and
+// punch, for servers that render the same popular hashes repeatedly.
+// Concurrent requests for a key that is not cached wait for a single
+// decode rather than each decoding it.
+//
+// Images and PNG bytes returned by a Cache are shared between callers and
+// must not be modified.
+//
+// A Cache is safe for concurrent use. Its options must not be changed
+// after first use. The zero value is ready to use.
+type Cache struct {
+	// Decoder holds the options used to decode placeholders. Its buffers
+	// are never used, so it may be a Decoder in use elsewhere.
+	Decoder Decoder
+
+	// MaxBytes bounds the total size of the cached entries: 4 bytes per
+	// pixel for images, or the length of the encoded PNGs. Zero means
+	// DefaultCacheBytes. Entries larger than the bound are returned but
+	// not cached.
+	MaxBytes int64
+
+	// StorePNG stores placeholders as PNG bytes rather than images,
+	// which usually take a small fraction of the memory but must be
+	// decoded again by Image.
+	StorePNG bool
+
+	once     sync.Once
+	decoders sync.Pool
+
+	mu      sync.Mutex
+	lru     list.List // of *cacheEntry, most recently used first
+	entries map[cacheKey]*list.Element
+	calls   map[cacheKey]*cacheCall
+	bytes   int64
+	stats   CacheStats
+}
+
+// CacheStats counts the requests served by a Cache.
+type CacheStats struct {
+	// Hits is the number of requests served from the cache.
+	Hits uint64
+	// Misses is the number of requests that decoded a placeholder.
+	Misses uint64
+	// Coalesced is the number of requests that waited for the decode of
+	// a concurrent miss.
+	Coalesced uint64
+	// Evictions is the number of entries evicted to respect MaxBytes.
+	Evictions uint64
+
+	// Entries is the number of cached placeholders.
+	Entries int
+	// Bytes is the total size of the cached placeholders.
+	Bytes int64
+}
+
+type cacheKey struct {
+	hash          string
+	width, height int
+	punch         uint64 // the bits of the punch, so that NaN is a key
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	img  *image.NRGBA
+	png  []byte
+	size int64
+}
+
+// testHookCacheDecode, if not nil, is called by every decode of a Cache.
+var testHookCacheDecode func()
+
+// cacheCall is a decode in progress, waited for by coalesced requests.
+type cacheCall struct {
+	done  chan struct{}
+	entry *cacheEntry
+	err   error
+}
+
+// init allocates the maps and decoder pool of c.
+func (c *Cache) init() {
+	c.entries = make(map[cacheKey]*list.Element)
+	c.calls = make(map[cacheKey]*cacheCall)
+	c.decoders.New = func() any {
+		// Copy the options alone, so that pooled decoders have their
+		// own buffers.
+		o := &c.Decoder
+		return &Decoder{
+			Deterministic: o.Deterministic,
+			Profile:       o.Profile,
+			Workers:       o.Workers,
+			Dither:        o.Dither,
+			DitherSeed:    o.DitherSeed,
+			Approximation: o.Approximation,
+			Detail:        o.Detail,
+			Fit:           o.Fit,
+		}
+	}
+}
+
+// Image returns the placeholder of the given size for the hash, decoding
+// it only if it is not cached. If c stores PNG bytes, they are decoded
+// into a new image on every call.
+func (c *Cache) Image(hash string, width, height int, punch float64) (image.Image, error) {
+	e, err := c.get(hash, width, height, punch)
+	if err != nil {
+		return nil, err
+	}
+	if e.img != nil {
+		return e.img, nil
+	}
+	return png.Decode(bytes.NewReader(e.png))
+}
+
+// PNG returns the placeholder of the given size for the hash encoded as a
+// PNG, decoding it only if it is not cached. If c stores images, they are
+// encoded on every call.
+func (c *Cache) PNG(hash string, width, height int, punch float64) ([]byte, error) {
+	e, err := c.get(hash, width, height, punch)
+	if err != nil {
+		return nil, err
+	}
+	if e.png != nil {
+		return e.png, nil
+	}
+	return encodePNG(e.img)
+}
+
+// Stats returns the statistics of c.
+func (c *Cache) Stats() CacheStats {
+	c.once.Do(c.init)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = c.lru.Len()
+	s.Bytes = c.bytes
+	return s
+}
+
+// get returns the entry for a key, decoding it or waiting for a
+// concurrent decode of it if it is not cached. Errors are not cached.
+func (c *Cache) get(hash string, width, height int, punch float64) (*cacheEntry, error) {
+	c.once.Do(c.init)
+	key := cacheKey{hash: hash, width: width, height: height, punch: math.Float64bits(punch)}
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		c.stats.Hits++
+		c.mu.Unlock()
+		return el.Value.(*cacheEntry), nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.stats.Coalesced++
+		c.mu.Unlock()
+		<-call.done
+		return call.entry, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	c.run(call, key, punch)
+	return call.entry, call.err
+}
+
+// run decodes the entry for a key into call and then releases the
+// requests waiting for it. A panic during the decode becomes call.err, so
+// that later requests for the key are not left waiting.
+func (c *Cache) run(call *cacheCall, key cacheKey, punch float64) {
+	defer func() {
+		if r := recover(); r != nil {
+			call.entry, call.err = nil, fmt.Errorf("blurhash: cache decode panicked: %v", r)
+		}
+		c.mu.Lock()
+		delete(c.calls, key)
+		if call.err == nil {
+			c.add(call.entry)
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	call.entry, call.err = c.decode(key, punch)
+}
+
+// decode decodes the entry for a key with a pooled decoder.
+func (c *Cache) decode(key cacheKey, punch float64) (*cacheEntry, error) {
+	// Reject sizes whose image would overflow before allocating it.
+	if key.width <= 0 || key.height <= 0 || key.width > math.MaxInt/4/key.height {
+		return nil, fmt.Errorf("%w: had width=%d, height=%d", ErrInvalidDimensions, key.width, key.height)
+	}
+	if testHookCacheDecode != nil {
+		testHookCacheDecode()
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, key.width, key.height))
+	d := c.decoders.Get().(*Decoder)
+	err := d.DecodeDraw(img, key.hash, punch)
+	c.decoders.Put(d)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &cacheEntry{key: key}
+	if !c.StorePNG {
+		e.img = img
+		e.size = int64(len(img.Pix))
+		return e, nil
+	}
+	if e.png, err = encodePNG(img); err != nil {
+		return nil, err
+	}
+	e.size = int64(len(e.png))
+	return e, nil
+}
+
+// add caches e, evicting the least recently used entries to respect
+// MaxBytes. c.mu must be held.
+func (c *Cache) add(e *cacheEntry) {
+	limit := c.MaxBytes
+	if limit == 0 {
+		limit = DefaultCacheBytes
+	}
+	if e.size > limit {
+		return
+	}
+	for c.bytes+e.size > limit {
+		oldest := c.lru.Back()
+		old := c.lru.Remove(oldest).(*cacheEntry)
+		delete(c.entries, old.key)
+		c.bytes -= old.size
+		c.stats.Evictions++
+	}
+	c.entries[e.key] = c.lru.PushFront(e)
+	c.bytes += e.size
+}
+
+// encodePNG returns img encoded as a PNG.
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
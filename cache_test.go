@@ -0,0 +1,226 @@
+package blurhash_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"math"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestCache(t *testing.T) {
+	for _, usePNG := range []bool{false, true} {
+		cache := &blurhash.Cache{
+			Decoder:  blurhash.Decoder{Dither: blurhash.DitherBayer},
+			StorePNG: usePNG,
+		}
+		dec := blurhash.Decoder{Dither: blurhash.DitherBayer}
+		for _, test := range testFixtures {
+			want := image.NewNRGBA(image.Rect(0, 0, 24, 16))
+			if err := dec.DecodeDraw(want, test.hash, 1.5); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+			for n := 0; n < 2; n++ {
+				img, err := cache.Image(test.hash, 24, 16, 1.5)
+				if err != nil {
+					t.Fatalf("error decoding with cache: %v", err)
+				}
+				assertSamePixels(t, img, want)
+
+				b, err := cache.PNG(test.hash, 24, 16, 1.5)
+				if err != nil {
+					t.Fatalf("error encoding with cache: %v", err)
+				}
+				img, err = png.Decode(bytes.NewReader(b))
+				if err != nil {
+					t.Fatalf("error decoding PNG: %v", err)
+				}
+				assertSamePixels(t, img, want)
+			}
+		}
+
+		// Each fixture missed once and then hit three times.
+		s := cache.Stats()
+		n := uint64(len(testFixtures))
+		if s.Misses != n || s.Hits != 3*n || s.Coalesced != 0 || s.Evictions != 0 || s.Entries != len(testFixtures) {
+			t.Errorf("png=%v: stats are %+v", usePNG, s)
+		}
+		if !usePNG && s.Bytes != int64(len(testFixtures)*24*16*4) {
+			t.Errorf("cache holds %d bytes, want %d", s.Bytes, len(testFixtures)*24*16*4)
+		}
+	}
+}
+
+func assertSamePixels(t *testing.T, img image.Image, want *image.NRGBA) {
+	t.Helper()
+	if img.Bounds() != want.Bounds() {
+		t.Fatalf("bounds are %v, want %v", img.Bounds(), want.Bounds())
+	}
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			wr, wg, wb, wa := want.At(x, y).RGBA()
+			if r != wr || g != wg || bl != wb || a != wa {
+				t.Fatalf("pixel (%d, %d) differs", x, y)
+			}
+		}
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	const size = 8 * 8 * 4
+	cache := &blurhash.Cache{MaxBytes: 2 * size}
+	a, b, c := testFixtures[0].hash, testFixtures[1].hash, testFixtures[2].hash
+	for _, hash := range []string{a, b, a, c} {
+		if _, err := cache.Image(hash, 8, 8, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+	}
+	// c evicted b, the least recently used, so a is still cached.
+	if _, err := cache.Image(a, 8, 8, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	s := cache.Stats()
+	if s.Hits != 2 || s.Misses != 3 || s.Evictions != 1 || s.Entries != 2 || s.Bytes != 2*size {
+		t.Errorf("stats are %+v", s)
+	}
+
+	// Entries larger than the bound are returned but not cached.
+	if _, err := cache.Image(a, 16, 16, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if s := cache.Stats(); s.Entries != 2 || s.Evictions != 1 {
+		t.Errorf("stats after oversized entry are %+v", s)
+	}
+}
+
+func TestCacheCoalesce(t *testing.T) {
+	var cache blurhash.Cache
+	hash := testFixtures[2].hash
+	want, err := blurhash.Decode(hash, 256, 256, 1)
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	const n = 16
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	imgs := make([]image.Image, n)
+	for g := 0; g < n; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			<-start
+			imgs[g], _ = cache.Image(hash, 256, 256, 1)
+		}(g)
+	}
+	close(start)
+	wg.Wait()
+
+	// Every request either waited for the one decode or hit its result.
+	s := cache.Stats()
+	if s.Misses != 1 || s.Hits+s.Coalesced != n-1 {
+		t.Errorf("stats are %+v", s)
+	}
+	for _, img := range imgs {
+		if img != imgs[0] {
+			t.Fatal("requests returned different images")
+		}
+	}
+	assertSamePixels(t, imgs[0], want.(*image.NRGBA))
+}
+
+func TestCacheCoalesceSlow(t *testing.T) {
+	var cache blurhash.Cache
+	const n = 8
+
+	// The decode waits until every other request is waiting for it.
+	restore := blurhash.SetCacheDecodeHook(func() {
+		for cache.Stats().Coalesced < n-1 {
+			runtime.Gosched()
+		}
+	})
+	defer restore()
+
+	var wg sync.WaitGroup
+	errs := make([]error, n)
+	for g := 0; g < n; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			_, errs[g] = cache.Image(testFixtures[0].hash, 32, 32, 1)
+		}(g)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+	}
+	if s := cache.Stats(); s.Misses != 1 || s.Coalesced != n-1 || s.Hits != 0 {
+		t.Errorf("stats are %+v", s)
+	}
+}
+
+func TestCachePanic(t *testing.T) {
+	var cache blurhash.Cache
+	hash := testFixtures[0].hash
+
+	// A decode that panics fails its request, and the next request for
+	// the key decodes again rather than waiting forever.
+	restore := blurhash.SetCacheDecodeHook(func() { panic("decode failed") })
+	if _, err := cache.Image(hash, 8, 8, 1); err == nil {
+		t.Fatal("expected an error from a panicking decode")
+	}
+	restore()
+	done := make(chan error)
+	go func() {
+		_, err := cache.Image(hash, 8, 8, 1)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("request after a panicking decode did not return")
+	}
+	if s := cache.Stats(); s.Misses != 2 || s.Entries != 1 {
+		t.Errorf("stats are %+v", s)
+	}
+}
+
+func TestCacheInvalid(t *testing.T) {
+	var cache blurhash.Cache
+	if _, err := cache.Image(testFixtures[0].hash, 0, 8, 1); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidDimensions, err)
+	}
+	if _, err := cache.Image(testFixtures[0].hash, math.MaxInt32, math.MaxInt32, 1); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidDimensions, err)
+	}
+	if _, err := cache.PNG("LFE.@D9F01_2%L%MIVD*9Goe-;W", 8, 8, 1); !errors.Is(err, blurhash.ErrInvalidHash) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidHash, err)
+	}
+	// Errors are not cached.
+	if s := cache.Stats(); s.Misses != 3 || s.Entries != 0 || s.Bytes != 0 {
+		t.Errorf("stats are %+v", s)
+	}
+}
+
+func BenchmarkCacheHit(b *testing.B) {
+	var cache blurhash.Cache
+	hash := testFixtures[0].hash
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = cache.Image(hash, 32, 32, 1)
+		}
+	})
+}
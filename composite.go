@@ -0,0 +1,244 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Shape is the outline of a placeholder drawn by DecodeComposite.
+type Shape int
+
+const (
+	// ShapeRect covers the whole of the destination.
+	ShapeRect Shape = iota
+
+	// ShapeRoundedRect covers the destination with its corners rounded
+	// to Composite.Radius.
+	ShapeRoundedRect
+
+	// ShapeCircle covers the largest circle centred in the destination.
+	ShapeCircle
+)
+
+// String returns the name of the shape.
+func (s Shape) String() string {
+	switch s {
+	case ShapeRect:
+		return "rect"
+	case ShapeRoundedRect:
+		return "rounded-rect"
+	case ShapeCircle:
+		return "circle"
+	}
+	return fmt.Sprintf("Shape(%d)", int(s))
+}
+
+// Composite configures how DecodeComposite draws a placeholder over the
+// existing content of its destination.
+type Composite struct {
+	// Opacity is the opacity of the placeholder, from 0, which leaves the
+	// destination unchanged, to 1.
+	Opacity float64
+
+	// Mask, if not nil, scales the opacity of each pixel by the alpha of
+	// the mask pixel aligned with it, as in draw.DrawMask: the top left
+	// pixel of the destination is aligned with MaskPoint. Pixels beyond
+	// the bounds of the mask are left unchanged.
+	Mask      image.Image
+	MaskPoint image.Point
+
+	// Shape selects an anti-aliased outline for the placeholder, combined
+	// with Mask.
+	Shape Shape
+
+	// Radius is the corner radius of ShapeRoundedRect in pixels. It is
+	// limited to half the shorter side of the destination.
+	Radius float64
+}
+
+// check returns an error if c has an opacity outside [0, 1], an unknown
+// shape or a negative radius.
+func (c *Composite) check() error {
+	if !(c.Opacity >= 0 && c.Opacity <= 1) {
+		return fmt.Errorf("%w: had opacity=%v", ErrInvalidComposite, c.Opacity)
+	}
+	if c.Shape < ShapeRect || c.Shape > ShapeCircle {
+		return fmt.Errorf("%w: %v", ErrInvalidComposite, c.Shape)
+	}
+	if !(c.Radius >= 0) {
+		return fmt.Errorf("%w: had radius=%v", ErrInvalidComposite, c.Radius)
+	}
+	return nil
+}
+
+// coverage returns the opacity of the placeholder at pixel (x, y) of a
+// destination with the given bounds.
+func (c *Composite) coverage(x, y int, bounds image.Rectangle) float64 {
+	a := c.Opacity
+	if c.Mask != nil {
+		a *= maskAlpha(c.Mask, c.MaskPoint.X+x-bounds.Min.X, c.MaskPoint.Y+y-bounds.Min.Y)
+	}
+	if c.Shape == ShapeRect || a == 0 {
+		return a
+	}
+
+	// Measure the signed distance from the centre of the pixel to the
+	// outline, relative to the centre of the destination, and cover the
+	// half pixel either side of it linearly.
+	hw, hh := float64(bounds.Dx())/2, float64(bounds.Dy())/2
+	px := float64(x-bounds.Min.X) + 0.5 - hw
+	py := float64(y-bounds.Min.Y) + 0.5 - hh
+	var dist float64
+	if c.Shape == ShapeCircle {
+		dist = math.Hypot(px, py) - math.Min(hw, hh)
+	} else {
+		r := math.Min(c.Radius, math.Min(hw, hh))
+		qx, qy := math.Abs(px)-(hw-r), math.Abs(py)-(hh-r)
+		dist = math.Hypot(math.Max(qx, 0), math.Max(qy, 0)) + math.Min(math.Max(qx, qy), 0) - r
+	}
+	return a * math.Max(0, math.Min(1, 0.5-dist))
+}
+
+// maskAlpha returns the alpha of pixel (x, y) of mask, from 0 to 1.
+func maskAlpha(mask image.Image, x, y int) float64 {
+	if !(image.Point{x, y}).In(mask.Bounds()) {
+		return 0
+	}
+	if m, ok := mask.(*image.Alpha); ok {
+		return float64(m.Pix[m.PixOffset(x, y)]) / 0xff
+	}
+	_, _, _, a := mask.At(x, y).RGBA()
+	return float64(a) / 0xffff
+}
+
+// DecodeComposite decodes a blurhash over the existing content of dst,
+// scaling its opacity by c. Each pixel is blended with the source over
+// operator in linear light, whether dst stores premultiplied or straight
+// alpha, and pixels with no coverage are left unchanged. With an opacity
+// of 1 and no mask or shape, the pixels are those DecodeDraw writes.
+//
+// *image.RGBA and *image.NRGBA are blended with 8-bit precision, and any
+// other draw.Image with 16-bit precision through its RGBA64 methods where
+// it has them. Dither and HighBitDepth do not apply.
+func (d *Decoder) DecodeComposite(dst draw.Image, hash string, punch float64, c Composite) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	bounds := dst.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	numX, numY, err := d.prepare(hash, width, height, punch)
+	if err != nil {
+		return err
+	}
+
+	workers := 1
+	switch dst.(type) {
+	case *image.RGBA, *image.NRGBA:
+		workers = d.Workers
+	}
+	d.parallelRows(workers, height, nil, func(w *rowWorker, y0, y1 int) {
+		// Rows are decoded to linear light, as for 16-bit writers.
+		w.reset(width, numX, d.Dither, false, true)
+		for y := y0; y < y1; y++ {
+			d.decodeRow(w, y, width, height, numX, numY)
+			d.compositeRow(w.linear, dst, &c, bounds.Min.Y+y)
+		}
+	})
+	return nil
+}
+
+// compositeRow blends linear, three values per pixel, over row y of dst.
+func (d *Decoder) compositeRow(linear []float64, dst draw.Image, c *Composite, y int) {
+	bounds := dst.Bounds()
+	switch img := dst.(type) {
+	case *image.NRGBA:
+		pix := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for i := 0; i < bounds.Dx(); i++ {
+			a := c.coverage(bounds.Min.X+i, y, bounds)
+			if a == 0 {
+				continue
+			}
+			p := pix[i*4 : i*4+4]
+			da := float64(p[3]) / 0xff
+			ao := a + float64(da*(1-a))
+			for ch := 0; ch < 3; ch++ {
+				co := blendOver(linear[i*3+ch], a, d.Profile.sRGBToLinear(int(p[ch])), da, ao)
+				p[ch] = uint8(d.Profile.linearToSRGB(co))
+			}
+			p[3] = uint8(float64(ao*0xff) + 0.5)
+		}
+	case *image.RGBA:
+		pix := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		for i := 0; i < bounds.Dx(); i++ {
+			a := c.coverage(bounds.Min.X+i, y, bounds)
+			if a == 0 {
+				continue
+			}
+			p := pix[i*4 : i*4+4]
+			da := float64(p[3]) / 0xff
+			ao := a + float64(da*(1-a))
+			for ch := 0; ch < 3; ch++ {
+				var dl float64
+				if p[3] > 0 {
+					dl = srgbLevelToLinear(float64(p[ch]) * 0xff / float64(p[3]))
+				}
+				co := blendOver(linear[i*3+ch], a, dl, da, ao)
+				p[ch] = uint8(float64(float64(d.Profile.linearToSRGB(co))*ao) + 0.5)
+			}
+			p[3] = uint8(float64(ao*0xff) + 0.5)
+		}
+	default:
+		rgba64, _ := dst.(draw.RGBA64Image)
+		for i := 0; i < bounds.Dx(); i++ {
+			x := bounds.Min.X + i
+			a := c.coverage(x, y, bounds)
+			if a == 0 {
+				continue
+			}
+			var px [4]uint32
+			if rgba64 != nil {
+				v := rgba64.RGBA64At(x, y)
+				px = [4]uint32{uint32(v.R), uint32(v.G), uint32(v.B), uint32(v.A)}
+			} else {
+				px[0], px[1], px[2], px[3] = dst.At(x, y).RGBA()
+			}
+			da := float64(px[3]) / 0xffff
+			ao := a + float64(da*(1-a))
+			var out [3]uint16
+			for ch := 0; ch < 3; ch++ {
+				var dl float64
+				if px[3] > 0 {
+					dl = srgbLevelToLinear(float64(px[ch]) * 0xff / float64(px[3]))
+				}
+				co := blendOver(linear[i*3+ch], a, dl, da, ao)
+				out[ch] = uint16(float64(float64(linearToSRGB16(co))*ao) + 0.5)
+			}
+			o := color.RGBA64{out[0], out[1], out[2], uint16(float64(ao*0xffff) + 0.5)}
+			if rgba64 != nil {
+				rgba64.SetRGBA64(x, y, o)
+			} else {
+				dst.Set(x, y, o)
+			}
+		}
+	}
+}
+
+// blendOver returns the straight linear value of the source over
+// operator for a source value s with coverage a over a destination value
+// dl with alpha da, whose result has alpha ao.
+func blendOver(s, a, dl, da, ao float64) float64 {
+	// Decoded values may overshoot, and are clamped as the writers clamp
+	// them. Explicit conversions prevent fused multiply-adds.
+	s = math.Max(0, math.Min(1, s))
+	return (float64(s*a) + float64(float64(dl*da)*(1-a))) / ao
+}
+
+// DecodeComposite decodes the given hash over the existing content of
+// dst with the given opacity, mask and shape.
+func DecodeComposite(dst draw.Image, hash string, punch float64, c Composite) error {
+	var d Decoder
+	return d.DecodeComposite(dst, hash, punch, c)
+}
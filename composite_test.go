@@ -0,0 +1,263 @@
+package blurhash_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+// srgbToLinear converts an 8-bit sRGB value to linear.
+func srgbToLinear(v uint8) float64 {
+	c := float64(v) / 255
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB converts a linear value to an 8-bit sRGB value.
+func linearToSRGB(v float64) int {
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func TestDecodeCompositeOpaque(t *testing.T) {
+	hash := testFixtures[0].hash
+	want := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	// Fully opaque compositing replaces any content, as DecodeDraw does.
+	content := gradientImage(color.NRGBA{R: 90})
+	for _, dst := range []draw.Image{image.NewNRGBA(content.Bounds()), image.NewRGBA(content.Bounds())} {
+		draw.Draw(dst, dst.Bounds(), content, image.Point{}, draw.Src)
+		if err := blurhash.DecodeComposite(dst, hash, 1, blurhash.Composite{Opacity: 1}); err != nil {
+			t.Fatalf("error compositing: %v", err)
+		}
+		assertSamePixels(t, dst, want)
+	}
+
+	// Other destinations are blended with 16-bit precision.
+	dst := image.NewNRGBA64(content.Bounds())
+	draw.Draw(dst, dst.Bounds(), content, image.Point{}, draw.Src)
+	if err := blurhash.DecodeComposite(dst, hash, 1, blurhash.Composite{Opacity: 1}); err != nil {
+		t.Fatalf("error compositing: %v", err)
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			c := color.NRGBAModel.Convert(dst.At(x, y)).(color.NRGBA)
+			w := want.NRGBAAt(x, y)
+			if absDiff(c.R, w.R) > 1 || absDiff(c.G, w.G) > 1 || absDiff(c.B, w.B) > 1 || c.A != 255 {
+				t.Fatalf("16-bit pixel (%d, %d) is %v, want %v", x, y, c, w)
+			}
+		}
+	}
+}
+
+func TestDecodeCompositeOpacity(t *testing.T) {
+	hash := testFixtures[1].hash
+	src := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	if err := blurhash.DecodeDraw(src, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	for _, bg := range []uint8{0, 255} {
+		dst := solidImage(color.NRGBA{bg, bg, bg, 255})
+		if err := blurhash.DecodeComposite(dst, hash, 1, blurhash.Composite{Opacity: 0.5}); err != nil {
+			t.Fatalf("error compositing: %v", err)
+		}
+		// Half the placeholder and half the background, in linear light.
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				s, c := src.NRGBAAt(x, y), dst.NRGBAAt(x, y)
+				for ch, v := range []uint8{s.R, s.G, s.B} {
+					want := linearToSRGB(0.5*srgbToLinear(v) + 0.5*srgbToLinear(bg))
+					got := int([]uint8{c.R, c.G, c.B}[ch])
+					if got < want-1 || got > want+1 {
+						t.Fatalf("background %d: channel %d of pixel (%d, %d) is %d, want %d", bg, ch, x, y, got, want)
+					}
+				}
+				if c.A != 255 {
+					t.Fatalf("pixel (%d, %d) has alpha %d, want 255", x, y, c.A)
+				}
+			}
+		}
+	}
+
+	// Over a transparent destination the placeholder keeps its colour.
+	dst := image.NewNRGBA(src.Bounds())
+	if err := blurhash.DecodeComposite(dst, hash, 1, blurhash.Composite{Opacity: 0.5}); err != nil {
+		t.Fatalf("error compositing: %v", err)
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			s, c := src.NRGBAAt(x, y), dst.NRGBAAt(x, y)
+			if c != (color.NRGBA{s.R, s.G, s.B, 128}) {
+				t.Fatalf("pixel (%d, %d) is %v, want %v at half alpha", x, y, c, s)
+			}
+		}
+	}
+
+	// A zero opacity leaves the destination unchanged.
+	content := gradientImage(color.NRGBA{R: 90})
+	dst = image.NewNRGBA(content.Bounds())
+	draw.Draw(dst, dst.Bounds(), content, image.Point{}, draw.Src)
+	if err := blurhash.DecodeComposite(dst, hash, 1, blurhash.Composite{}); err != nil {
+		t.Fatalf("error compositing: %v", err)
+	}
+	assertSamePixels(t, dst, content)
+}
+
+func TestDecodeCompositeAlpha(t *testing.T) {
+	// Premultiplied and straight destinations of the same translucent
+	// colour blend to the same colour.
+	hash := testFixtures[2].hash
+	bg := color.NRGBA{200, 100, 50, 96}
+	straight := solidImage(bg)
+	premultiplied := image.NewRGBA(straight.Bounds())
+	draw.Draw(premultiplied, premultiplied.Bounds(), straight, image.Point{}, draw.Src)
+	wide := image.NewRGBA64(straight.Bounds())
+	draw.Draw(wide, wide.Bounds(), straight, image.Point{}, draw.Src)
+
+	c := blurhash.Composite{Opacity: 0.6}
+	for _, dst := range []draw.Image{straight, premultiplied, wide} {
+		if err := blurhash.DecodeComposite(dst, hash, 1, c); err != nil {
+			t.Fatalf("error compositing: %v", err)
+		}
+	}
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			want := straight.NRGBAAt(x, y)
+			if want.A != 191 {
+				t.Fatalf("pixel (%d, %d) has alpha %d, want 191", x, y, want.A)
+			}
+			for _, img := range []image.Image{premultiplied, wide} {
+				got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				if absDiff(got.R, want.R) > 2 || absDiff(got.G, want.G) > 2 || absDiff(got.B, want.B) > 2 || absDiff(got.A, want.A) > 1 {
+					t.Fatalf("%T pixel (%d, %d) is %v, want %v", img, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeCompositeMask(t *testing.T) {
+	hash := testFixtures[0].hash
+	want := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	bg := color.NRGBA{10, 20, 30, 255}
+
+	// The mask is offset by MaskPoint, so only its opaque left half
+	// covers the destination, and pixels beyond it are left unchanged.
+	mask := image.NewAlpha(image.Rect(0, 0, 24, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 12; x++ {
+			mask.SetAlpha(x, y, color.Alpha{255})
+		}
+	}
+	for _, m := range []image.Image{mask, mask.SubImage(mask.Rect)} {
+		dst := solidImage(bg)
+		c := blurhash.Composite{Opacity: 1, Mask: m, MaskPoint: image.Point{4, -4}}
+		if err := blurhash.DecodeComposite(dst, hash, 1, c); err != nil {
+			t.Fatalf("error compositing: %v", err)
+		}
+		for y := 0; y < 16; y++ {
+			for x := 0; x < 16; x++ {
+				w := bg
+				if x < 8 && y >= 4 && y < 12 {
+					w = want.NRGBAAt(x, y)
+				}
+				if got := dst.NRGBAAt(x, y); got != w {
+					t.Fatalf("pixel (%d, %d) is %v, want %v", x, y, got, w)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeCompositeShapes(t *testing.T) {
+	hash := testFixtures[0].hash
+	want := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	bg := color.NRGBA{0, 0, 0, 0}
+
+	for _, c := range []blurhash.Composite{
+		{Opacity: 1, Shape: blurhash.ShapeCircle},
+		{Opacity: 1, Shape: blurhash.ShapeRoundedRect, Radius: 8},
+		{Opacity: 1, Shape: blurhash.ShapeRoundedRect, Radius: 100},
+	} {
+		dst := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+		if err := blurhash.DecodeComposite(dst, hash, 1, c); err != nil {
+			t.Fatalf("error compositing: %v", err)
+		}
+		// The corners are untouched, the centre is opaque, and the
+		// outline is anti-aliased.
+		for _, p := range []image.Point{{0, 0}, {31, 0}, {0, 23}, {31, 23}} {
+			if got := dst.NRGBAAt(p.X, p.Y); got != bg {
+				t.Errorf("%v: corner %v is %v, want %v", c.Shape, p, got, bg)
+			}
+		}
+		if got, w := dst.NRGBAAt(16, 12), want.NRGBAAt(16, 12); got != w {
+			t.Errorf("%v: centre is %v, want %v", c.Shape, got, w)
+		}
+		partial := 0
+		for y := 0; y < 24; y++ {
+			for x := 0; x < 32; x++ {
+				if a := dst.NRGBAAt(x, y).A; a > 0 && a < 255 {
+					partial++
+				}
+			}
+		}
+		if partial == 0 {
+			t.Errorf("%v: outline is not anti-aliased", c.Shape)
+		}
+	}
+
+	// A square corner is fully covered.
+	dst := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	c := blurhash.Composite{Opacity: 1, Shape: blurhash.ShapeRoundedRect}
+	if err := blurhash.DecodeComposite(dst, hash, 1, c); err != nil {
+		t.Fatalf("error compositing: %v", err)
+	}
+	assertSamePixels(t, dst, want)
+}
+
+func TestDecodeCompositeInvalid(t *testing.T) {
+	hash := testFixtures[0].hash
+	dst := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for _, c := range []blurhash.Composite{
+		{Opacity: 1.5},
+		{Opacity: -0.1},
+		{Opacity: math.NaN()},
+		{Opacity: 1, Shape: 3},
+		{Opacity: 1, Shape: blurhash.ShapeRoundedRect, Radius: -1},
+	} {
+		if err := blurhash.DecodeComposite(dst, hash, 1, c); !errors.Is(err, blurhash.ErrInvalidComposite) {
+			t.Errorf("%+v: expected %v, got %v", c, blurhash.ErrInvalidComposite, err)
+		}
+	}
+	if err := blurhash.DecodeComposite(dst, hash[:5], 1, blurhash.Composite{Opacity: 1}); !errors.Is(err, blurhash.ErrInvalidHash) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidHash, err)
+	}
+}
+
+func BenchmarkDecodeComposite(b *testing.B) {
+	hash := testFixtures[0].hash
+	dst := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	c := blurhash.Composite{Opacity: 0.8, Shape: blurhash.ShapeRoundedRect, Radius: 12}
+	for i := 0; i < b.N; i++ {
+		_ = blurhash.DecodeComposite(dst, hash, 1, c)
+	}
+}
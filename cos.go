@@ -0,0 +1,73 @@
+package blurhash
+
+import "math"
+
+// fillCosines fills table with cos(π·i·x/n) for every component i and
+// sample x, indexed as table[i*n+x]. If deterministic is set, exactCos is
+// used so the table is identical on every platform.
+func fillCosines(table []float64, components, n int, deterministic bool) {
+	for i := 0; i < components; i++ {
+		for x := 0; x < n; x++ {
+			if deterministic {
+				table[i*n+x] = exactCos(i*x, n)
+			} else {
+				table[i*n+x] = math.Cos(math.Pi * float64(i) * float64(x) / float64(n))
+			}
+		}
+	}
+}
+
+// exactCos returns cos(π·k/n) for k >= 0 and n > 0.
+//
+// Unlike math.Cos, which is implemented in assembly on some platforms and
+// whose polynomial evaluation may be fused into FMA instructions on others,
+// exactCos uses only correctly rounded IEEE 754 operations with explicit
+// rounding between them, so its result is bit-identical on every GOARCH.
+// It is accurate to within a couple of ulps of the true value.
+func exactCos(k, n int) float64 {
+	// Reduce the angle to [0, π/4] using exact integer arithmetic.
+	k %= 2 * n
+	if k > n {
+		k = 2*n - k // cos(2π-θ) = cos(θ)
+	}
+	sign := 1.0
+	if 2*k > n {
+		k = n - k // cos(π-θ) = -cos(θ)
+		sign = -1
+	}
+	if 4*k > n {
+		// cos(θ) = sin(π/2-θ), with π/2-θ = π(n-2k)/2n
+		return sign * sinPoly(math.Pi*float64(n-2*k)/float64(2*n))
+	}
+	return sign * cosPoly(math.Pi*float64(k)/float64(n))
+}
+
+// cosPoly evaluates the Taylor series of cos(x) for x in [0, π/4].
+// The float64 conversions prevent the compiler fusing multiply-adds.
+func cosPoly(x float64) float64 {
+	x2 := float64(x * x)
+	p := 1.0 / 20922789888000 // 1/16!
+	p = float64(p*x2) - 1.0/87178291200
+	p = float64(p*x2) + 1.0/479001600
+	p = float64(p*x2) - 1.0/3628800
+	p = float64(p*x2) + 1.0/40320
+	p = float64(p*x2) - 1.0/720
+	p = float64(p*x2) + 1.0/24
+	p = float64(p*x2) - 1.0/2
+	return float64(p*x2) + 1
+}
+
+// sinPoly evaluates the Taylor series of sin(x) for x in [0, π/4].
+// The float64 conversions prevent the compiler fusing multiply-adds.
+func sinPoly(x float64) float64 {
+	x2 := float64(x * x)
+	p := 1.0 / 355687428096000 // 1/17!
+	p = float64(p*x2) - 1.0/1307674368000
+	p = float64(p*x2) + 1.0/6227020800
+	p = float64(p*x2) - 1.0/39916800
+	p = float64(p*x2) + 1.0/362880
+	p = float64(p*x2) - 1.0/5040
+	p = float64(p*x2) + 1.0/120
+	p = float64(p*x2) - 1.0/6
+	return float64(float64(p*x2)*x) + x
+}
@@ -0,0 +1,33 @@
+package blurhash
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExactCos(t *testing.T) {
+	for n := 1; n <= 256; n++ {
+		for k := 0; k < 9*n; k++ {
+			got := exactCos(k, n)
+			// The argument to math.Cos is itself rounded, which alone
+			// accounts for errors of a few ulps near zero crossings.
+			want := math.Cos(math.Pi * float64(k%(2*n)) / float64(n))
+			if math.Abs(got-want) > 1e-14 {
+				t.Fatalf("exactCos(%d, %d) = %v, want %v", k, n, got, want)
+			}
+		}
+	}
+}
+
+func TestExactCosSymmetry(t *testing.T) {
+	for n := 1; n <= 64; n++ {
+		for k := 0; k <= n; k++ {
+			if a, b := exactCos(k, n), -exactCos(n-k, n); a != b && !(a == 0 && b == 0) {
+				t.Fatalf("exactCos(%d, %d) = %v should be the negation of exactCos(%d, %d) = %v", k, n, a, n-k, n, -b)
+			}
+		}
+		if n%2 == 0 && exactCos(n/2, n) != 0 {
+			t.Errorf("exactCos(%d, %d) should be exactly zero, got %v", n/2, n, exactCos(n/2, n))
+		}
+	}
+}
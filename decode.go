@@ -3,7 +3,6 @@ package blurhash
 import (
 	"fmt"
 	"image"
-	"image/color"
 	"image/draw"
 	"math"
 
@@ -41,12 +40,82 @@ func Components(hash string) (x, y int, err error) {
 // by reusing internal buffers across decode operations.
 //
 // A Decoder is safe for sequential use but not for concurrent use.
-// For concurrent workloads, use a sync.Pool of Decoders.
+// For concurrent workloads, use a sync.Pool of Decoders, or a Plan for
+// images of one size.
 //
 // The zero value is ready to use.
 type Decoder struct {
+	// Deterministic guarantees bit-identical pixels on every GOARCH by
+	// computing cosines with exact argument reduction and correctly
+	// rounded arithmetic instead of math.Cos.
+	Deterministic bool
+
+	// Profile selects the version of the decoding arithmetic. The zero
+	// value is ProfileV12.
+	Profile Profile
+
+	// Workers is the number of goroutines that decode bands of rows
+	// concurrently. Output is identical to decoding on one goroutine.
+	// Zero or one decodes on the calling goroutine.
+	//
+	// Destinations that DecodeDraw does not write directly are always
+	// decoded on the calling goroutine, since their Set methods may not
+	// be safe for concurrent use.
+	Workers int
+
+	// Dither selects how decoded colours are quantised to 8 bits. The
+	// zero value, DitherNone, rounds to the nearest level.
+	Dither Dither
+
+	// DitherSeed shifts the DitherBayer and DitherBlueNoise patterns, so
+	// that neighbouring placeholders need not share one. Output is
+	// reproducible for a given seed.
+	DitherSeed uint64
+
+	// HighBitDepth decodes into *image.RGBA64, *image.NRGBA64 and
+	// *image.Gray16 with full 16-bit precision, evaluating the exact sRGB
+	// transfer function rather than widening 8-bit values. Gradients
+	// exported from such images, for example as 16-bit PNGs, show no
+	// banding. Dither does not apply to these destinations.
+	HighBitDepth bool
+
+	// Approximation selects a faster decode that evaluates the hash on a
+	// coarse grid and interpolates between its samples. The zero value
+	// decodes every pixel exactly.
+	Approximation Approximation
+
+	// Detail limits decoding to the low frequency components of a hash,
+	// for progressive rendering or cheaper placeholders. The zero value
+	// decodes every component.
+	Detail LevelOfDetail
+
+	// Fit places the hash in the image preserving the aspect ratio of its
+	// source, as CSS object-fit does. The zero value stretches the hash
+	// to the image. Approximation does not apply to fitted decodes.
+	Fit Fit
+
 	cosX, cosY []float64
 	colors     [][3]float64
+	palette    [][4]uint32
+	workers    []rowWorker
+
+	// Buffers used by DecodeTiles.
+	tileColors         [][3]float64
+	tileOffsets        []int
+	tileSizes          [][2]int
+	spansX, spansY     []tileSpan
+	tileCosX, tileCosY []float64
+
+	// Buffers used by approximate decoding.
+	gridWidth, gridHeight int
+	grid                  []float64
+	gridColors            [][3]float64
+	gridCosX, gridCosY    []float64
+	gridTapsX, gridTapsY  []gridTap
+
+	// Buffers used by fitted decoding.
+	fitX, fitY        fitAxis
+	fitted, fitBorder bool
 }
 
 // NewDecoder creates a new reusable Decoder.
@@ -70,101 +139,171 @@ func (d *Decoder) Decode(hash string, width, height, punch int) (image.Image, er
 
 // DecodeDraw decodes a blurhash into an existing image.
 // Internal buffers are reused across calls when possible.
+//
+// The standard library image types are written directly. Any other
+// draw.Image is written with SetRGBA64 where it implements
+// draw.RGBA64Image, and Set otherwise.
 func (d *Decoder) DecodeDraw(dst draw.Image, hash string, punch float64) error {
-	numX, numY, err := Components(hash)
+	bounds := dst.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	numX, numY, err := d.prepare(hash, width, height, punch)
 	if err != nil {
 		return err
 	}
+	d.drawRows(dst, numX, numY)
+	return nil
+}
 
-	quantisedMaximumValue, err := base83.Decode(string(hash[1]))
-	if err != nil {
-		return err
-	}
-	maximumValue := float64(quantisedMaximumValue+1) / 166
+// drawRows writes the rows of dst from the colours and cosine tables
+// filled by prepare, which it only reads.
+func (d *Decoder) drawRows(dst draw.Image, numX, numY int) {
+	bounds := dst.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	d.writeRows(dst, numX, func(w *rowWorker, y int) {
+		d.decodeRow(w, y, width, height, numX, numY)
+	})
+}
 
+// writeRows writes the rows of dst, each filled into a worker by decode
+// as decodeRow fills it, on as many workers as dst and Dither allow.
+func (d *Decoder) writeRows(dst draw.Image, numX int, decode func(w *rowWorker, y int)) {
 	bounds := dst.Bounds()
 	width, height := bounds.Dx(), bounds.Dy()
+	p, paletted := dst.(*image.Paletted)
+	if paletted {
+		d.loadPalette(p.Palette)
+	}
+
+	wide := d.HighBitDepth && writesWide(dst)
+	workers := 1
+	if writesDirectly(dst) && (wide || !d.Dither.sequential()) {
+		workers = d.Workers
+	}
+	d.parallelRows(workers, height, nil, func(w *rowWorker, y0, y1 int) {
+		w.reset(width, numX, d.Dither, paletted, wide)
+		for y := y0; y < y1; y++ {
+			decode(w, y)
+			d.writeRow(w, dst, bounds.Min.X, bounds.Min.Y+y)
+		}
+	})
+}
+
+// prepare decodes the colours of hash and fills the cosine tables for
+// an image of the given size, or the grid of an approximate decode,
+// returning the hash's components.
+func (d *Decoder) prepare(hash string, width, height int, punch float64) (numX, numY int, err error) {
+	if err := d.Profile.check(); err != nil {
+		return 0, 0, err
+	}
+	if err := d.Dither.check(); err != nil {
+		return 0, 0, err
+	}
+	if err := d.Approximation.check(); err != nil {
+		return 0, 0, err
+	}
+	if err := d.Detail.check(); err != nil {
+		return 0, 0, err
+	}
+	if err := d.Fit.check(); err != nil {
+		return 0, 0, err
+	}
+
+	numX, numY, err = Components(hash)
+	if err != nil {
+		return 0, 0, err
+	}
 
 	// Ensure buffers are large enough
 	d.maybeGrowBuffers(width, height, numX, numY)
 
 	// Decode colors into reusable buffer
-	numColors := numX * numY
-	for i := 0; i < numColors; i++ {
-		if i == 0 {
-			val, err := base83.Decode(hash[2:6])
-			if err != nil {
-				return err
-			}
-			d.colors[i] = decodeDC(val)
-		} else {
-			val, err := base83.Decode(hash[4+i*2 : 6+i*2])
-			if err != nil {
-				return err
-			}
-			d.colors[i] = decodeAC(val, maximumValue*punch)
-		}
+	if err := decodeColors(d.colors, hash, punch, d.Profile); err != nil {
+		return 0, 0, err
+	}
+	numX, numY = d.Detail.apply(d.colors, numX, numY)
+
+	if d.prepareFit(width, height) {
+		// The grid of an approximate decode spans the hash, not the image.
+		d.gridWidth, d.gridHeight = 0, 0
+	} else if d.prepareGrid(width, height, numX, numY) {
+		return numX, numY, nil
 	}
 
 	// Compute cosine tables into reusable buffers
-	for i := 0; i < numX; i++ {
-		for x := 0; x < width; x++ {
-			d.cosX[i*width+x] = math.Cos(math.Pi * float64(i) * float64(x) / float64(width))
-		}
+	d.fillAxisCosines(d.cosX, numX, d.fitX)
+	d.fillAxisCosines(d.cosY, numY, d.fitY)
+
+	return numX, numY, nil
+}
+
+// decodeRow fills w.row with the sRGB values of row y of the image,
+// three bytes per pixel. If w.wide is set, it instead leaves the linear
+// values of the row in w.linear for a 16-bit writer.
+func (d *Decoder) decodeRow(w *rowWorker, y, width, height, numX, numY int) {
+	d.evaluateRow(w, y, width, height, numX, numY)
+	if d.fitBorder {
+		d.fillBorder(w, y, width)
+	}
+}
+
+// evaluateRow evaluates row y of the image into w.row or w.linear, as
+// decodeRow does.
+//
+// The basis functions are separable, so the y bases are first collapsed
+// into one colour per x component, and only those are evaluated for each
+// pixel of the row. This reduces the work from O(w·h·x·y) to roughly
+// O(w·h·x + h·x·y).
+func (d *Decoder) evaluateRow(w *rowWorker, y, width, height, numX, numY int) {
+	if d.gridWidth > 0 {
+		d.decodeGridRow(w, y, width)
+		return
 	}
-	for j := 0; j < numY; j++ {
-		for y := 0; y < height; y++ {
-			d.cosY[j*height+y] = math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+	if d.Profile == ProfileReference {
+		d.referenceDecodeRow(w.linear, y, width, height, numX, numY)
+		if !w.wide {
+			d.quantiseRow(w, y)
 		}
+		return
 	}
 
-	// Get direct pixel access if available
-	var pix []uint8
-	var stride int
-	switch img := dst.(type) {
-	case *image.NRGBA:
-		pix = img.Pix
-		stride = img.Stride
-	case *image.RGBA:
-		pix = img.Pix
-		stride = img.Stride
-	}
-
-	// Account for sub-image offset
-	minX, minY := bounds.Min.X, bounds.Min.Y
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			var r, g, b float64
-			for j := 0; j < numY; j++ {
-				basisY := d.cosY[j*height+y]
-				for i := 0; i < numX; i++ {
-					basis := d.cosX[i*width+x] * basisY
-					compColor := d.colors[i+j*numX]
-					r += compColor[0] * basis
-					g += compColor[1] * basis
-					b += compColor[2] * basis
-				}
-			}
+	keepLinear := w.wide || d.Dither != DitherNone
 
-			if pix != nil {
-				idx := (minY+y)*stride + (minX+x)*4
-				pix[idx] = uint8(linearToSRGB(r))
-				pix[idx+1] = uint8(linearToSRGB(g))
-				pix[idx+2] = uint8(linearToSRGB(b))
-				pix[idx+3] = 255
-			} else {
-				dst.Set(minX+x, minY+y, color.NRGBA{
-					uint8(linearToSRGB(r)),
-					uint8(linearToSRGB(g)),
-					uint8(linearToSRGB(b)),
-					255,
-				})
-			}
+	for i := 0; i < numX; i++ {
+		var r, g, b float64
+		for j := 0; j < numY; j++ {
+			basisY := d.cosY[j*height+y]
+			compColor := d.colors[i+j*numX]
+			// Explicit conversions prevent fused multiply-adds.
+			r += float64(compColor[0] * basisY)
+			g += float64(compColor[1] * basisY)
+			b += float64(compColor[2] * basisY)
 		}
+		w.rowColors[i] = [3]float64{r, g, b}
 	}
 
-	return nil
+	for x := 0; x < width; x++ {
+		var r, g, b float64
+		for i := 0; i < numX; i++ {
+			basisX := d.cosX[i*width+x]
+			compColor := w.rowColors[i]
+			r += float64(compColor[0] * basisX)
+			g += float64(compColor[1] * basisX)
+			b += float64(compColor[2] * basisX)
+		}
+
+		if keepLinear {
+			w.linear[x*3] = r
+			w.linear[x*3+1] = g
+			w.linear[x*3+2] = b
+			continue
+		}
+		w.row[x*3] = uint8(d.Profile.linearToSRGB(r))
+		w.row[x*3+1] = uint8(d.Profile.linearToSRGB(g))
+		w.row[x*3+2] = uint8(d.Profile.linearToSRGB(b))
+	}
+	if keepLinear && !w.wide {
+		d.quantiseRow(w, y)
+	}
 }
 
 func (d *Decoder) maybeGrowBuffers(width, height, numX, numY int) {
@@ -185,10 +324,38 @@ func DecodeDraw(dst draw.Image, hash string, punch float64) error {
 	return d.DecodeDraw(dst, hash, punch)
 }
 
-func decodeDC(val int) (c [3]float64) {
-	c[0] = sRGBToLinear(val >> 16 & 255)
-	c[1] = sRGBToLinear(val >> 8 & 255)
-	c[2] = sRGBToLinear(val & 255)
+// decodeColors decodes the DC and AC components of a hash, whose length
+// has already been validated by Components, into colors using the
+// arithmetic of p.
+func decodeColors(colors [][3]float64, hash string, punch float64, p Profile) error {
+	quantisedMaximumValue, err := base83.Decode(string(hash[1]))
+	if err != nil {
+		return err
+	}
+	maximumValue := float64(quantisedMaximumValue+1) / 166
+
+	for i := range colors {
+		if i == 0 {
+			val, err := base83.Decode(hash[2:6])
+			if err != nil {
+				return err
+			}
+			colors[i] = decodeDC(val, p)
+		} else {
+			val, err := base83.Decode(hash[4+i*2 : 6+i*2])
+			if err != nil {
+				return err
+			}
+			colors[i] = decodeAC(val, maximumValue*punch)
+		}
+	}
+	return nil
+}
+
+func decodeDC(val int, p Profile) (c [3]float64) {
+	c[0] = p.sRGBToLinear(val >> 16 & 255)
+	c[1] = p.sRGBToLinear(val >> 8 & 255)
+	c[2] = p.sRGBToLinear(val & 255)
 	return c
 }
 
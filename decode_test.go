@@ -3,6 +3,7 @@ package blurhash_test
 import (
 	"errors"
 	"image"
+	"image/color"
 	"image/png"
 	"io"
 	"testing"
@@ -189,6 +190,37 @@ func TestDecodeDrawSubImage(t *testing.T) {
 	}
 }
 
+func TestDecodeDrawOffset(t *testing.T) {
+	want := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	if err := blurhash.DecodeDraw(want, testFixtures[0].hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	// Sub-images and images with a non-zero origin must both be written
+	// in place, pixel for pixel.
+	parent := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	dsts := []*image.NRGBA{
+		parent.SubImage(image.Rect(10, 20, 42, 52)).(*image.NRGBA),
+		image.NewNRGBA(image.Rect(-7, 5, 25, 37)),
+	}
+	for _, dst := range dsts {
+		if err := blurhash.DecodeDraw(dst, testFixtures[0].hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		origin := dst.Bounds().Min
+		for y := 0; y < 32; y++ {
+			for x := 0; x < 32; x++ {
+				if got, w := dst.NRGBAAt(origin.X+x, origin.Y+y), want.NRGBAAt(x, y); got != w {
+					t.Fatalf("bounds %v: pixel (%d, %d) = %v, want %v", dst.Bounds(), x, y, got, w)
+				}
+			}
+		}
+	}
+	if c := parent.NRGBAAt(9, 19); c != (color.NRGBA{}) {
+		t.Errorf("pixel outside sub-image was written: %v", c)
+	}
+}
+
 func TestDecodeInvalidDimensions(t *testing.T) {
 	tests := []struct {
 		name   string
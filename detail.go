@@ -0,0 +1,189 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"sort"
+)
+
+// ComponentOrder is the order in which a LevelOfDetail keeps components.
+type ComponentOrder int
+
+const (
+	// OrderZigZag keeps components along the anti-diagonals of the
+	// component grid, alternating direction, as JPEG orders coefficients.
+	OrderZigZag ComponentOrder = iota
+
+	// OrderFrequency keeps components by increasing spatial frequency,
+	// i²+j² for component (i, j), breaking ties in zig-zag order.
+	OrderFrequency
+)
+
+// String returns the name of the order.
+func (o ComponentOrder) String() string {
+	switch o {
+	case OrderZigZag:
+		return "zig-zag"
+	case OrderFrequency:
+		return "frequency"
+	}
+	return fmt.Sprintf("ComponentOrder(%d)", int(o))
+}
+
+// LevelOfDetail limits decoding to the low frequency components of a
+// hash, for progressive rendering or cheaper placeholders in long lists.
+// A component of a hash outside the limits is decoded as if it were zero,
+// and the grid of components evaluated shrinks to the smallest holding
+// those kept, so that decoding fewer components is cheaper.
+//
+// The zero value decodes every component.
+type LevelOfDetail struct {
+	// Components is the number of components kept, in the order selected
+	// by Order, from those within MaxX and MaxY. Zero keeps them all.
+	Components int
+
+	// Order selects which components Components keeps.
+	Order ComponentOrder
+
+	// MaxX and MaxY keep only the first MaxX components across and MaxY
+	// down. Zero means no limit.
+	MaxX, MaxY int
+}
+
+// check returns an error if l has negative limits or an unknown order.
+func (l LevelOfDetail) check() error {
+	if l.Order < OrderZigZag || l.Order > OrderFrequency {
+		return fmt.Errorf("%w: %v", ErrInvalidDetail, l.Order)
+	}
+	if l.Components < 0 || l.MaxX < 0 || l.MaxY < 0 {
+		return fmt.Errorf("%w: had components=%d, x=%d, y=%d", ErrInvalidDetail, l.Components, l.MaxX, l.MaxY)
+	}
+	return nil
+}
+
+// grid returns the components across and down within MaxX and MaxY of a
+// hash with numX by numY components.
+func (l LevelOfDetail) grid(numX, numY int) (int, int) {
+	if l.MaxX > 0 {
+		numX = minInt(numX, l.MaxX)
+	}
+	if l.MaxY > 0 {
+		numY = minInt(numY, l.MaxY)
+	}
+	return numX, numY
+}
+
+// apply reduces colors, which holds the numX by numY components of a
+// hash, to those l keeps. It repacks them in place as the smallest grid
+// holding every kept component and returns the size of that grid.
+func (l LevelOfDetail) apply(colors [][3]float64, numX, numY int) (int, int) {
+	gx, gy := l.grid(numX, numY)
+	var keep [maxComponents * maxComponents]bool
+	if l.Components > 0 && l.Components < gx*gy {
+		var order [maxComponents * maxComponents]int
+		ordered := componentOrder(order[:0], l.Order, gx, gy)
+		bx, by := 1, 1
+		for _, k := range ordered[:l.Components] {
+			i, j := k%gx, k/gx
+			keep[i+j*numX] = true
+			bx, by = maxInt(bx, i+1), maxInt(by, j+1)
+		}
+		gx, gy = bx, by
+	} else {
+		for j := 0; j < gy; j++ {
+			for i := 0; i < gx; i++ {
+				keep[i+j*numX] = true
+			}
+		}
+	}
+
+	// Each component moves to an index no greater than its own, so
+	// ascending order reads every component before it is overwritten.
+	for j := 0; j < gy; j++ {
+		for i := 0; i < gx; i++ {
+			c := colors[i+j*numX]
+			if !keep[i+j*numX] {
+				c = [3]float64{}
+			}
+			colors[i+j*gx] = c
+		}
+	}
+	return gx, gy
+}
+
+// componentOrder appends the indices i+j*numX of the numX by numY
+// components to dst in the given order.
+func componentOrder(dst []int, order ComponentOrder, numX, numY int) []int {
+	start := len(dst)
+	for s := 0; s < numX+numY-1; s++ {
+		// Odd anti-diagonals run down and to the left, even ones up and
+		// to the right, starting rightwards from the DC component.
+		for n := 0; n <= s; n++ {
+			i := n
+			if s%2 == 1 {
+				i = s - n
+			}
+			if j := s - i; i < numX && j < numY {
+				dst = append(dst, i+j*numX)
+			}
+		}
+	}
+	if order == OrderFrequency {
+		ordered := dst[start:]
+		sort.SliceStable(ordered, func(a, b int) bool {
+			ia, ja := ordered[a]%numX, ordered[a]/numX
+			ib, jb := ordered[b]%numX, ordered[b]/numX
+			return ia*ia+ja*ja < ib*ib+jb*jb
+		})
+	}
+	return dst
+}
+
+// Refine decodes frames of the given size that refine the hash
+// progressively, for focus-in animations. The first frame reconstructs
+// only the DC component and the last every component within d.Detail.
+// Those in between add components in the order of d.Detail, in even
+// steps. If frames is less than 1 or more than the components kept,
+// there is a frame for each.
+func (d *Decoder) Refine(hash string, width, height int, punch float64, frames int) ([]*image.NRGBA, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("%w: had width=%d, height=%d", ErrInvalidDimensions, width, height)
+	}
+	if err := d.Detail.check(); err != nil {
+		return nil, err
+	}
+	numX, numY, err := Components(hash)
+	if err != nil {
+		return nil, err
+	}
+	gx, gy := d.Detail.grid(numX, numY)
+	total := gx * gy
+	if d.Detail.Components > 0 {
+		total = minInt(total, d.Detail.Components)
+	}
+	if frames < 1 || frames > total {
+		frames = total
+	}
+
+	detail := d.Detail
+	defer func() { d.Detail = detail }()
+	imgs := make([]*image.NRGBA, frames)
+	for k := range imgs {
+		d.Detail.Components = total
+		if frames > 1 {
+			d.Detail.Components = 1 + k*(total-1)/(frames-1)
+		}
+		imgs[k] = image.NewNRGBA(image.Rect(0, 0, width, height))
+		if err := d.DecodeDraw(imgs[k], hash, punch); err != nil {
+			return nil, err
+		}
+	}
+	return imgs, nil
+}
+
+// Refine decodes frames of the given size that refine the hash
+// progressively.
+func Refine(hash string, width, height int, punch float64, frames int) ([]*image.NRGBA, error) {
+	var d Decoder
+	return d.Refine(hash, width, height, punch, frames)
+}
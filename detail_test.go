@@ -0,0 +1,206 @@
+package blurhash_test
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+	"github.com/bbrks/go-blurhash/base83"
+)
+
+// zeroComponents returns hash with every AC component not in keep set to
+// zero, which decodes to a zero colour. The components of keep are given
+// as (i, j) pairs.
+func zeroComponents(t *testing.T, hash string, keep [][2]int) string {
+	t.Helper()
+	numX, _, err := blurhash.Components(hash)
+	if err != nil {
+		t.Fatalf("error reading components: %v", err)
+	}
+	zero, err := base83.Encode(9*19*19+9*19+9, 2)
+	if err != nil {
+		t.Fatalf("error encoding zero: %v", err)
+	}
+	kept := make(map[int]bool)
+	for _, c := range keep {
+		kept[c[0]+c[1]*numX] = true
+	}
+	b := []byte(hash)
+	for k := 1; 6+k*2 <= len(hash); k++ {
+		if !kept[k] {
+			copy(b[4+k*2:], zero)
+		}
+	}
+	return string(b)
+}
+
+func TestLevelOfDetail(t *testing.T) {
+	// The orders of the components of a 4x3 hash.
+	orders := map[blurhash.ComponentOrder][][2]int{
+		blurhash.OrderZigZag: {
+			{0, 0}, {1, 0}, {0, 1}, {0, 2}, {1, 1}, {2, 0},
+			{3, 0}, {2, 1}, {1, 2}, {2, 2}, {3, 1}, {3, 2},
+		},
+		blurhash.OrderFrequency: {
+			{0, 0}, {1, 0}, {0, 1}, {1, 1}, {0, 2}, {2, 0},
+			{2, 1}, {1, 2}, {2, 2}, {3, 0}, {3, 1}, {3, 2},
+		},
+	}
+	hash := testFixtures[0].hash
+	for order, components := range orders {
+		for _, profile := range []blurhash.Profile{blurhash.ProfileV12, blurhash.ProfileReference} {
+			for n := 1; n <= len(components); n++ {
+				want := image.NewNRGBA(image.Rect(0, 0, 29, 17))
+				full := blurhash.Decoder{Profile: profile}
+				if err := full.DecodeDraw(want, zeroComponents(t, hash, components[:n]), 1); err != nil {
+					t.Fatalf("error decoding: %v", err)
+				}
+
+				dec := blurhash.Decoder{Profile: profile, Detail: blurhash.LevelOfDetail{Components: n, Order: order}}
+				got := image.NewNRGBA(want.Bounds())
+				if err := dec.DecodeDraw(got, hash, 1); err != nil {
+					t.Fatalf("error decoding with detail: %v", err)
+				}
+				for i := range want.Pix {
+					if got.Pix[i] != want.Pix[i] {
+						t.Fatalf("%v %v, %d components: byte %d is %d, want %d", order, profile, n, i, got.Pix[i], want.Pix[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestLevelOfDetailLimits(t *testing.T) {
+	hash := testFixtures[2].hash // 5x5 components
+	var keep [][2]int
+	for j := 0; j < 2; j++ {
+		for i := 0; i < 3; i++ {
+			keep = append(keep, [2]int{i, j})
+		}
+	}
+	want, err := blurhash.Decode(zeroComponents(t, hash, keep), 32, 24, 1)
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	// Limits beyond the components of the hash have no effect.
+	for _, detail := range []blurhash.LevelOfDetail{
+		{MaxX: 3, MaxY: 2},
+		{MaxX: 3, MaxY: 2, Components: 6},
+		{MaxX: 3, MaxY: 2, Components: 81, Order: blurhash.OrderFrequency},
+	} {
+		dec := blurhash.Decoder{Detail: detail}
+		got, err := dec.Decode(hash, 32, 24, 1)
+		if err != nil {
+			t.Fatalf("error decoding with detail: %v", err)
+		}
+		if !sameNRGBA(got, want) {
+			t.Errorf("%+v: decoded differently from the truncated hash", detail)
+		}
+
+		// Placeholders apply the same limits.
+		p, err := dec.Placeholder(hash, 32, 24, 1)
+		if err != nil {
+			t.Fatalf("error creating placeholder: %v", err)
+		}
+		for y := 0; y < 24; y++ {
+			for x := 0; x < 32; x++ {
+				if p.NRGBAAt(x, y) != want.(*image.NRGBA).NRGBAAt(x, y) {
+					t.Fatalf("%+v: placeholder pixel (%d, %d) differs", detail, x, y)
+				}
+			}
+		}
+	}
+}
+
+func sameNRGBA(a, b image.Image) bool {
+	pa, pb := a.(*image.NRGBA).Pix, b.(*image.NRGBA).Pix
+	if len(pa) != len(pb) {
+		return false
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRefine(t *testing.T) {
+	hash := testFixtures[1].hash // 4x3 components
+	frames, err := blurhash.Refine(hash, 16, 16, 1, 0)
+	if err != nil {
+		t.Fatalf("error refining: %v", err)
+	}
+	if len(frames) != 12 {
+		t.Fatalf("had %d frames, want 12", len(frames))
+	}
+
+	// The first frame is the solid DC colour and the last the full decode.
+	dc := frames[0].NRGBAAt(0, 0)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if c := frames[0].NRGBAAt(x, y); c != dc {
+				t.Fatalf("DC frame pixel (%d, %d) is %v, want %v", x, y, c, dc)
+			}
+		}
+	}
+	full, err := blurhash.Decode(hash, 16, 16, 1)
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	if !sameNRGBA(frames[11], full) {
+		t.Error("last frame differs from the full decode")
+	}
+
+	// Fewer frames step evenly through the components: 1, 4, 8 and 12.
+	dec := blurhash.Decoder{Workers: 2}
+	frames4, err := dec.Refine(hash, 16, 16, 1, 4)
+	if err != nil {
+		t.Fatalf("error refining: %v", err)
+	}
+	for k, n := range []int{1, 4, 8, 12} {
+		if !sameNRGBA(frames4[k], frames[n-1]) {
+			t.Errorf("frame %d differs from the frame of %d components", k, n)
+		}
+	}
+	if dec.Detail != (blurhash.LevelOfDetail{}) {
+		t.Errorf("Refine left the decoder's detail as %+v", dec.Detail)
+	}
+}
+
+func TestLevelOfDetailInvalid(t *testing.T) {
+	hash := testFixtures[0].hash
+	for _, detail := range []blurhash.LevelOfDetail{
+		{Components: -1},
+		{MaxX: -1},
+		{Order: 2},
+	} {
+		dec := blurhash.Decoder{Detail: detail}
+		if _, err := dec.Decode(hash, 8, 8, 1); !errors.Is(err, blurhash.ErrInvalidDetail) {
+			t.Errorf("%+v: expected %v, got %v", detail, blurhash.ErrInvalidDetail, err)
+		}
+		if _, err := dec.Refine(hash, 8, 8, 1, 0); !errors.Is(err, blurhash.ErrInvalidDetail) {
+			t.Errorf("%+v: expected %v, got %v", detail, blurhash.ErrInvalidDetail, err)
+		}
+	}
+	if _, err := blurhash.Refine(hash, 8, 0, 1, 0); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidDimensions, err)
+	}
+}
+
+func BenchmarkDecodeDetail(b *testing.B) {
+	hash := testFixtures[2].hash
+	dst := image.NewNRGBA(image.Rect(0, 0, 256, 256))
+	for _, n := range []int{1, 6, 0} {
+		dec := blurhash.Decoder{Detail: blurhash.LevelOfDetail{Components: n}}
+		b.Run(fmt.Sprintf("components=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = dec.DecodeDraw(dst, hash, 1)
+			}
+		})
+	}
+}
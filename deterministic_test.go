@@ -0,0 +1,86 @@
+package blurhash_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+// deterministicHashes are golden hashes produced by a deterministic
+// Encoder. They must be identical on every GOARCH.
+var deterministicHashes = []struct {
+	file         string
+	xComp, yComp int
+	hash         string
+}{
+	{"fixtures/test.png", 4, 3, "LFE.@D9F01_2%L%MIVD*9Goe-;WB"},
+	{"fixtures/test.png", 9, 9, "|FE.@D9F01_2M{xu%LRjM{%L%MIVD*%MRjxut7M{9Goe-;WBIVt6WBj[xu4ot7-:Rjt7WBM{ofofM|M{%LxtM{xaWBRjoeIUWB%Mj@RjofRkj[j[kBt6Rjofj[M|t7WBof-:j[IVWBt7ofM|j[t7t7ofRjj[ofRjt7WCWB"},
+	{"fixtures/test.png", 1, 1, "00E.@D"},
+	{"fixtures/test.png", 3, 7, "uFE.@D9F01%L%MIV9Goe-;4ot7-:M|M{%LIUWB%MkBt6Rj"},
+	{"fixtures/octocat.png", 4, 3, "LNAdApj[00aymkj[TKay9}ay-Sj["},
+	{"fixtures/octocat.png", 9, 9, "|NAdApj[00ayxGayx]j[R*mkj[TKayt7fQaKj[WB9}ay-Sj[NHj[Wqayt74:fk?HjtNGf6Rkays;s8j[R-f6t7ayadfQWB_3f69Zfkn$j[xuj[WVVXj[g4ayozj@e-j[WBXoj[nNf6V@aybIaykCMwaytSj[oefQWBayWC"},
+	{"fixtures/octocat.png", 1, 1, "00AdAp"},
+	{"fixtures/octocat.png", 3, 7, "uNAdApj[00mkj[TK9}ay-S4:fk?Hs8j[R-_3f69ZVXj[g4"},
+	{"fixtures/dalle.png", 5, 5, "eaF#5R0#WBjYR+58-nWCWBn~bIsTbbayjFWof8jFj[WX-nNHR*jss."},
+	{"fixtures/dalle.png", 9, 9, "|aF#5R0#WBjYR+sBSzoLbI58-nWCWBn~bGn+ofbHbIsTbbayjFR*WXoea|Wof8jFj[WXoLj@jaoL-nNHR*jss.a#jtWVafIpt5s:RkbGofWDWooKR+j?WCofWBn%oLbHjZbaafoej[ofR+s,ayWVjZoLR+jGoLofWCWVoL"},
+	{"fixtures/dalle.png", 1, 1, "00F#5R"},
+	{"fixtures/dalle.png", 3, 7, "uaF#5R0#WB58-nWCbIsTbbWof8jF-nNHR*Ipt5s:R+j?WC"},
+}
+
+// deterministicPixels are SHA-256 digests of the NRGBA pixels produced by
+// a deterministic Decoder. They must be identical on every GOARCH.
+var deterministicPixels = []struct {
+	hash          string
+	width, height int
+	digest        string
+}{
+	{"LFE.@D9F01_2%L%MIVD*9Goe-;WB", 32, 32, "d747339f80e96e80e1b5ce217355a51eb476e2a23a5b68bbf5133910eaf433e2"},
+	{"LFE.@D9F01_2%L%MIVD*9Goe-;WB", 61, 17, "4d59d0aefe4e78a4b80fa9c76f4816cf872f4f535969d5908dd562451bff0062"},
+	{"LNAdApj[00aymkj[TKay9}ay-Sj[", 32, 32, "9651caed98c0d631cc53a9718f779db494fe5d8ec6853cda6e66bb83f3e86fc6"},
+	{"LNAdApj[00aymkj[TKay9}ay-Sj[", 61, 17, "d1fd060666774d454a011028e8df5f4e6100a0de9a2b6e33a497c0ea2cae1a64"},
+	{"eaF#5R0#WBjYR+58-nWCWBn~bIsTbbayjFWof8jFj[WX-nNHR*jss.", 32, 32, "a28be0f4b156e7580fba961d7c3bfe077e29b0684d94a70cfbbf525f13ad0ae3"},
+	{"eaF#5R0#WBjYR+58-nWCWBn~bIsTbbayjFWof8jFj[WX-nNHR*jss.", 61, 17, "481169efa8826b6f05027edacca24ae6149a1842b2b4527803c641a14d5e7e4c"},
+	{"LNMF%n00%#MwS|WCWEM{R*bbWBbH", 32, 32, "3c1a35b8b860059b2a294d2f5f4334d7ce3c4594a23d0f8a2584223d9a3e2d4e"},
+	{"LNMF%n00%#MwS|WCWEM{R*bbWBbH", 61, 17, "570f0abd519bccb1c09e9a500d858bd4eae473b8bd88a1cf0c3f771aaa3a85ed"},
+	{"KJG8_@Dgx]_4V?xuyE%NRj", 32, 32, "43acba3c200126b2cc0b575ca2c2edd322911feacdb3e38b571e484fc26a4a04"},
+	{"KJG8_@Dgx]_4V?xuyE%NRj", 61, 17, "2776163bd5a28ac23ef0e24a10429c3a33daa1ac81b51b26f80f5d6cfa63476c"},
+}
+
+func TestDeterministicEncode(t *testing.T) {
+	images := map[string]image.Image{}
+	enc := blurhash.Encoder{Deterministic: true}
+
+	for _, test := range deterministicHashes {
+		img, ok := images[test.file]
+		if !ok {
+			img = blurhash.LoadFixture(t, test.file)
+			images[test.file] = img
+		}
+
+		hash, err := enc.Encode(test.xComp, test.yComp, img)
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if hash != test.hash {
+			t.Errorf("%s %dx%d: hash mismatch: got %q, want %q", test.file, test.xComp, test.yComp, hash, test.hash)
+		}
+	}
+}
+
+func TestDeterministicDecode(t *testing.T) {
+	dec := blurhash.Decoder{Deterministic: true}
+
+	for _, test := range deterministicPixels {
+		dst := image.NewNRGBA(image.Rect(0, 0, test.width, test.height))
+		if err := dec.DecodeDraw(dst, test.hash, 1); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		sum := sha256.Sum256(dst.Pix)
+		if got := hex.EncodeToString(sum[:]); got != test.digest {
+			t.Errorf("%s %dx%d: pixel digest mismatch: got %s, want %s", test.hash, test.width, test.height, got, test.digest)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+package blurhash
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Dither selects how a Decoder quantises decoded colours to 8 bits.
+//
+// Large placeholders of smooth gradients show visible banding when every
+// pixel is rounded to the nearest level. Dithering trades the bands for
+// fine noise. Every mode is deterministic, so a given hash, size and
+// DitherSeed always decode to the same pixels on a given GOARCH.
+// Dithering evaluates the sRGB transfer function with math.Pow, so the
+// cross-architecture guarantee of Deterministic does not extend to it.
+type Dither int
+
+const (
+	// DitherNone rounds every value to the nearest 8-bit level using
+	// the arithmetic of the decoder's Profile.
+	DitherNone Dither = iota
+
+	// DitherBayer adds an 8x8 ordered dither pattern before rounding.
+	DitherBayer
+
+	// DitherBlueNoise adds a 64x64 blue-noise pattern before rounding,
+	// which is less structured than DitherBayer.
+	DitherBlueNoise
+
+	// DitherFloydSteinberg diffuses the rounding error of each pixel to
+	// its neighbours, scanning rows in alternating directions. Rows
+	// depend on the rows above them, so Workers is ignored.
+	DitherFloydSteinberg
+)
+
+// String returns the name of the dither mode.
+func (d Dither) String() string {
+	switch d {
+	case DitherNone:
+		return "none"
+	case DitherBayer:
+		return "bayer"
+	case DitherBlueNoise:
+		return "blue-noise"
+	case DitherFloydSteinberg:
+		return "floyd-steinberg"
+	}
+	return fmt.Sprintf("Dither(%d)", int(d))
+}
+
+// check returns an error if d is not a known dither mode.
+func (d Dither) check() error {
+	if d < DitherNone || d > DitherFloydSteinberg {
+		return fmt.Errorf("%w: %v", ErrInvalidDither, d)
+	}
+	return nil
+}
+
+// sequential reports whether rows must be decoded in order on a single
+// goroutine.
+func (d Dither) sequential() bool {
+	return d == DitherFloydSteinberg
+}
+
+// quantiseRow fills w.row with the 8-bit sRGB values of w.linear, which
+// holds row y of the image.
+func (d *Decoder) quantiseRow(w *rowWorker, y int) {
+	switch d.Dither {
+	case DitherBayer:
+		ox, oy := ditherOffset(d.DitherSeed)
+		row := bayerMatrix[(y+oy)&7]
+		for x := 0; x < len(w.linear)/3; x++ {
+			t := (float64(row[(x+ox)&7]) + 0.5) / 64
+			ditherPixel(w, x, t)
+		}
+	case DitherBlueNoise:
+		noise := blueNoise()
+		ox, oy := ditherOffset(d.DitherSeed)
+		row := noise[((y+oy)&(blueNoiseSize-1))*blueNoiseSize:]
+		for x := 0; x < len(w.linear)/3; x++ {
+			t := (float64(row[(x+ox)&(blueNoiseSize-1)]) + 0.5) / blueNoiseSize / blueNoiseSize
+			ditherPixel(w, x, t)
+		}
+	case DitherFloydSteinberg:
+		diffuseRow(w, y)
+	default:
+		for i, v := range w.linear {
+			w.row[i] = uint8(d.Profile.linearToSRGB(v))
+		}
+	}
+}
+
+// ditherPixel quantises pixel x of w.linear, adding the threshold t in
+// (0, 1) before rounding down.
+func ditherPixel(w *rowWorker, x int, t float64) {
+	for c := 0; c < 3; c++ {
+		w.row[x*3+c] = clampLevel(math.Floor(srgbLevel(w.linear[x*3+c]) + t))
+	}
+}
+
+// diffuseRow quantises w.linear with Floyd-Steinberg error diffusion,
+// scanning even rows left to right and odd rows right to left. Rows must
+// be quantised in order, starting from row 0.
+func diffuseRow(w *rowWorker, y int) {
+	width := len(w.linear) / 3
+	cur, next := w.diffusion[y&1], w.diffusion[(y+1)&1]
+	if y == 0 {
+		for i := range cur {
+			cur[i] = 0
+		}
+	}
+	for i := range next {
+		next[i] = 0
+	}
+
+	x, end, dir := 0, width, 1
+	if y&1 == 1 {
+		x, end, dir = width-1, -1, -1
+	}
+	for ; x != end; x += dir {
+		// Errors are indexed from one pixel before the row, so the
+		// neighbours of the first and last pixel stay in bounds.
+		i, fwd, back := (x+1)*3, (x+1+dir)*3, (x+1-dir)*3
+		for c := 0; c < 3; c++ {
+			// Clamping first stops out of gamut values from diffusing
+			// errors that no neighbour can absorb.
+			v := math.Max(0, math.Min(255, srgbLevel(w.linear[x*3+c]))) + cur[i+c]
+			q := clampLevel(math.Floor(v + 0.5))
+			w.row[x*3+c] = q
+
+			// Explicit conversions prevent fused multiply-adds.
+			e := v - float64(q)
+			cur[fwd+c] += float64(e * (7.0 / 16))
+			next[back+c] += float64(e * (3.0 / 16))
+			next[i+c] += float64(e * (5.0 / 16))
+			next[fwd+c] += float64(e * (1.0 / 16))
+		}
+	}
+}
+
+// srgbLevel converts a linear value to sRGB on the 8-bit scale, without
+// clamping or rounding.
+func srgbLevel(v float64) float64 {
+	if v <= 0.0031308 {
+		return float64(v*12.92) * 255
+	}
+	return float64(float64(1.055*math.Pow(v, 1/2.4))-0.055) * 255
+}
+
+// clampLevel converts an integral level to a byte, clamping it to
+// [0, 255].
+func clampLevel(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// ditherOffset derives the offset of the dither pattern from seed.
+func ditherOffset(seed uint64) (x, y int) {
+	// The finaliser of SplitMix64.
+	z := seed + 0x9e3779b97f4a7c15
+	z = (z ^ z>>30) * 0xbf58476d1ce4e5b9
+	z = (z ^ z>>27) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return int(z & (blueNoiseSize - 1)), int(z >> 32 & (blueNoiseSize - 1))
+}
+
+// bayerMatrix is the 8x8 Bayer index matrix.
+var bayerMatrix = [8][8]uint8{
+	{0, 32, 8, 40, 2, 34, 10, 42},
+	{48, 16, 56, 24, 50, 18, 58, 26},
+	{12, 44, 4, 36, 14, 46, 6, 38},
+	{60, 28, 52, 20, 62, 30, 54, 22},
+	{3, 35, 11, 43, 1, 33, 9, 41},
+	{51, 19, 59, 27, 49, 17, 57, 25},
+	{15, 47, 7, 39, 13, 45, 5, 37},
+	{63, 31, 55, 23, 61, 29, 53, 21},
+}
+
+// blueNoiseSize is the side of the blue-noise pattern, a power of two.
+const blueNoiseSize = 64
+
+var (
+	blueNoiseOnce    sync.Once
+	blueNoisePattern []uint16
+)
+
+// blueNoise returns the blue-noise pattern, the rank of each of its
+// cells in row-major order, generating it on first use.
+func blueNoise() []uint16 {
+	blueNoiseOnce.Do(func() {
+		blueNoisePattern = voidAndCluster(blueNoiseSize, 1.5)
+	})
+	return blueNoisePattern
+}
+
+// voidAndCluster generates a size by size blue-noise pattern with
+// Ulichney's void-and-cluster method, using a Gaussian filter of the
+// given sigma on a torus.
+//
+// Energies are integers, so the pattern is the same on every GOARCH.
+func voidAndCluster(size int, sigma float64) []uint16 {
+	n := size * size
+	radius := int(math.Ceil(4 * sigma))
+	side := 2*radius + 1
+	kernel := make([]int64, side*side)
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			g := math.Exp(-float64(dx*dx+dy*dy) / (2 * sigma * sigma))
+			kernel[(dy+radius)*side+dx+radius] = int64(math.Round(g * (1 << 16)))
+		}
+	}
+
+	ones := make([]bool, n)
+	energy := make([]int64, n)
+	set := func(p int, on bool) {
+		ones[p] = on
+		sign := int64(1)
+		if !on {
+			sign = -1
+		}
+		px, py := p%size, p/size
+		for dy := -radius; dy <= radius; dy++ {
+			row := ((py + dy + size) % size) * size
+			for dx := -radius; dx <= radius; dx++ {
+				energy[row+(px+dx+size)%size] += sign * kernel[(dy+radius)*side+dx+radius]
+			}
+		}
+	}
+	// tightestCluster and largestVoid return the first of any ties, so
+	// the pattern does not depend on anything but its parameters.
+	tightestCluster := func() int {
+		best := -1
+		for p := 0; p < n; p++ {
+			if ones[p] && (best < 0 || energy[p] > energy[best]) {
+				best = p
+			}
+		}
+		return best
+	}
+	largestVoid := func() int {
+		best := -1
+		for p := 0; p < n; p++ {
+			if !ones[p] && (best < 0 || energy[p] < energy[best]) {
+				best = p
+			}
+		}
+		return best
+	}
+
+	// Scatter an initial tenth of the cells with a fixed xorshift
+	// sequence, then move points from clusters to voids until stable.
+	initial := n / 10
+	state := uint32(2463534242)
+	for count := 0; count < initial; {
+		state ^= state << 13
+		state ^= state >> 17
+		state ^= state << 5
+		if p := int(state % uint32(n)); !ones[p] {
+			set(p, true)
+			count++
+		}
+	}
+	for {
+		c := tightestCluster()
+		set(c, false)
+		v := largestVoid()
+		set(v, true)
+		if v == c {
+			break
+		}
+	}
+	start := append([]bool(nil), ones...)
+	startEnergy := append([]int64(nil), energy...)
+
+	rank := make([]uint16, n)
+	// Rank the initial points by removing the tightest cluster first.
+	for r := initial - 1; r >= 0; r-- {
+		c := tightestCluster()
+		set(c, false)
+		rank[c] = uint16(r)
+	}
+	// Rank the remaining cells by filling the largest void first.
+	copy(ones, start)
+	copy(energy, startEnergy)
+	for r := initial; r < n; r++ {
+		v := largestVoid()
+		set(v, true)
+		rank[v] = uint16(r)
+	}
+	return rank
+}
@@ -0,0 +1,171 @@
+package blurhash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// shallowGradientHash returns the hash of a horizontal ramp spanning only
+// a few 8-bit levels, which bands badly when decoded at a large size.
+func shallowGradientHash(t *testing.T) string {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(100 + x*12/63)
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v + 20, 255})
+		}
+	}
+	hash, err := Encode(2, 1, img)
+	if err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	return hash
+}
+
+func TestDitherBlockMeans(t *testing.T) {
+	const width, height, block = 512, 32, 8
+	hash := shallowGradientHash(t)
+
+	// The unquantised sRGB levels of the decoded image. Rows only keep
+	// their linear values when dithering.
+	d := Decoder{Dither: DitherBayer}
+	numX, numY, err := d.prepare(hash, width, height, 1)
+	if err != nil {
+		t.Fatalf("error preparing: %v", err)
+	}
+	var w rowWorker
+	w.reset(width, numX, d.Dither, false, false)
+	ideal := make([]float64, width*height*3)
+	for y := 0; y < height; y++ {
+		d.decodeRow(&w, y, width, height, numX, numY)
+		for i, v := range w.linear {
+			ideal[y*width*3+i] = srgbLevel(v)
+		}
+	}
+
+	// maxBlockError returns the largest difference between the mean of
+	// a block of img and the mean of its ideal levels.
+	maxBlockError := func(img *image.NRGBA) float64 {
+		var worst float64
+		for by := 0; by < height; by += block {
+			for bx := 0; bx < width; bx += block {
+				for c := 0; c < 3; c++ {
+					var got, want float64
+					for y := by; y < by+block; y++ {
+						for x := bx; x < bx+block; x++ {
+							got += float64(img.Pix[y*img.Stride+x*4+c])
+							want += ideal[(y*width+x)*3+c]
+						}
+					}
+					worst = math.Max(worst, math.Abs(got-want)/block/block)
+				}
+			}
+		}
+		return worst
+	}
+
+	errs := make(map[Dither]float64)
+	for _, dither := range []Dither{DitherNone, DitherBayer, DitherBlueNoise, DitherFloydSteinberg} {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		dec := Decoder{Dither: dither}
+		if err := dec.DecodeDraw(img, hash, 1); err != nil {
+			t.Fatalf("%v: error decoding: %v", dither, err)
+		}
+		errs[dither] = maxBlockError(img)
+	}
+
+	if errs[DitherNone] < 0.3 {
+		t.Fatalf("expected rounding to band, but block error is only %.3f", errs[DitherNone])
+	}
+	for _, dither := range []Dither{DitherBayer, DitherBlueNoise, DitherFloydSteinberg} {
+		if errs[dither] > 0.2 {
+			t.Errorf("%v: block error %.3f, want at most 0.2 (rounding gives %.3f)", dither, errs[dither], errs[DitherNone])
+		}
+	}
+}
+
+func TestDitherReproducible(t *testing.T) {
+	hash := shallowGradientHash(t)
+	decode := func(dec Decoder) []uint8 {
+		img := image.NewNRGBA(image.Rect(0, 0, 97, 41))
+		if err := dec.DecodeDraw(img, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		return img.Pix
+	}
+	equal := func(a, b []uint8) bool {
+		return string(a) == string(b)
+	}
+
+	for _, dither := range []Dither{DitherBayer, DitherBlueNoise, DitherFloydSteinberg} {
+		want := decode(Decoder{Dither: dither, DitherSeed: 7})
+		if got := decode(Decoder{Dither: dither, DitherSeed: 7}); !equal(got, want) {
+			t.Errorf("%v: output differs between decodes", dither)
+		}
+		if got := decode(Decoder{Dither: dither, DitherSeed: 7, Workers: 4}); !equal(got, want) {
+			t.Errorf("%v: output differs with workers", dither)
+		}
+
+		reseeded := decode(Decoder{Dither: dither, DitherSeed: 8})
+		if seeded := dither != DitherFloydSteinberg; seeded == equal(reseeded, want) {
+			t.Errorf("%v: seeded=%v, but changing the seed gave equal=%v", dither, seeded, equal(reseeded, want))
+		}
+	}
+}
+
+func TestDitherInvalid(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for _, dither := range []Dither{-1, DitherFloydSteinberg + 1} {
+		dec := Decoder{Dither: dither}
+		if err := dec.DecodeDraw(img, "LEHV6nWB2yk8pyo0adR*.7kCMdnj", 1); !errors.Is(err, ErrInvalidDither) {
+			t.Errorf("%v: expected ErrInvalidDither, got %v", dither, err)
+		}
+	}
+	if s := Dither(9).String(); s != "Dither(9)" {
+		t.Errorf("unexpected name %q", s)
+	}
+}
+
+func TestBlueNoisePattern(t *testing.T) {
+	noise := blueNoise()
+	seen := make([]bool, len(noise))
+	for _, r := range noise {
+		if seen[r] {
+			t.Fatalf("rank %d appears more than once", r)
+		}
+		seen[r] = true
+	}
+
+	// The pattern is part of the output of DitherBlueNoise, so it must
+	// never change.
+	h := sha256.New()
+	_ = binary.Write(h, binary.LittleEndian, noise)
+	if got, want := hex.EncodeToString(h.Sum(nil)), blueNoiseDigest; got != want {
+		t.Errorf("pattern digest = %s, want %s", got, want)
+	}
+
+	// Blue noise has little low-frequency energy: the thresholds of any
+	// 4x4 block should average close to the middle.
+	for by := 0; by < blueNoiseSize; by += 4 {
+		for bx := 0; bx < blueNoiseSize; bx += 4 {
+			var sum float64
+			for y := by; y < by+4; y++ {
+				for x := bx; x < bx+4; x++ {
+					sum += float64(noise[y*blueNoiseSize+x])
+				}
+			}
+			if mean := sum / 16 / float64(len(noise)); math.Abs(mean-0.5) > 0.2 {
+				t.Errorf("block (%d, %d) mean threshold %.3f", bx, by, mean)
+			}
+		}
+	}
+}
+
+const blueNoiseDigest = "b21fa11b2dc61de9d8411df338bf512ff77f1232e73edd24359b4272c0ccf05f"
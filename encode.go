@@ -27,14 +27,47 @@ const (
 // by reusing internal buffers across encode operations.
 //
 // An Encoder is safe for sequential use but not for concurrent use.
-// For concurrent workloads, use a sync.Pool of Encoders.
+// For concurrent workloads, use a sync.Pool of Encoders, or a Plan for
+// images of one size.
 //
 // The zero value is ready to use.
 type Encoder struct {
+	// Optimise selects an optional search for the quantised values that
+	// best reconstruct the image. The zero value uses the standard
+	// rounding rule. Optimised hashes decode with any blurhash decoder.
+	Optimise Optimisation
+
+	// Deterministic guarantees bit-identical hashes on every GOARCH by
+	// computing cosines with exact argument reduction and correctly
+	// rounded arithmetic instead of math.Cos. Hashes may differ from
+	// non-deterministic output in rare rounding edge cases.
+	// Rendered Optimise modes are not covered by this guarantee.
+	Deterministic bool
+
+	// Profile selects the version of the encoding arithmetic. The zero
+	// value is ProfileV12.
+	Profile Profile
+
 	cosX, cosY []float64
 	factors    [][3]float64
 	nrgba      *image.NRGBA
 	builder    strings.Builder
+
+	// Buffers used by optimised quantisation.
+	quant, quantStd       quantHash
+	colors                [][3]float64
+	gridWidth, gridHeight int
+	gridCosX, gridCosY    []float64
+	linearTarget          [][3]float64
+	target, render        [][3]float64
+	targetSpace           colorSpace
+	targetValid           bool
+	counts                []int
+
+	// Buffers used by EncodeBuffer for formats converted before encoding.
+	rgb          []uint8
+	tapsX, tapsY []chromaTap
+	cbRow, crRow []int
 }
 
 // NewEncoder creates a new reusable Encoder.
@@ -46,126 +79,194 @@ func NewEncoder() *Encoder {
 // Encode returns the blurhash for the given image.
 // Internal buffers are reused across calls when possible.
 func (e *Encoder) Encode(xComponents, yComponents int, img image.Image) (string, error) {
-	if xComponents < minComponents || xComponents > maxComponents ||
-		yComponents < minComponents || yComponents > maxComponents {
-		return "", fmt.Errorf("%w: had x=%d, y=%d", ErrInvalidComponents, xComponents, yComponents)
+	if err := e.computeFactors(xComponents, yComponents, img, e.Optimise.rendered()); err != nil {
+		return "", err
 	}
 
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-
-	// Ensure buffers are large enough
-	e.maybeGrowBuffers(width, height, xComponents, yComponents)
-
-	// Reset builder for new encode
-	e.builder.Reset()
+	return e.writeQuantised(e.quantiseOptimised(xComponents, yComponents, e.factors))
+}
 
-	sizeFlag := (xComponents - 1) + (yComponents-1)*9
-	sizeFlagEncoded, err := base83.Encode(sizeFlag, 1)
-	if err != nil {
-		return "", err
+// computeFactors fills e.factors with the DCT factors of img.
+// If downsample is set, the source grid used to compare rendered
+// candidates is also computed.
+func (e *Encoder) computeFactors(xComponents, yComponents int, img image.Image, downsample bool) error {
+	if err := checkComponents(xComponents, yComponents); err != nil {
+		return err
+	}
+	if err := e.Profile.check(); err != nil {
+		return err
 	}
-	e.builder.WriteString(sizeFlagEncoded)
 
+	bounds := img.Bounds()
+	pix, stride := e.packedPixels(img)
+	e.computePackedFactors(xComponents, yComponents, pix, stride, layoutRGBA, bounds.Dx(), bounds.Dy(), downsample)
+	return nil
+}
+
+// packedPixels returns the pixels of img as 4-byte RGBA or NRGBA,
+// converting them into e.nrgba if img is of any other type.
+func (e *Encoder) packedPixels(img image.Image) (pix []uint8, stride int) {
 	// Get direct 4-byte per pixel data - fast path [N]RGBA
-	var pix []uint8
-	var stride int
 	switch src := img.(type) {
 	case *image.NRGBA:
-		pix = src.Pix
-		stride = src.Stride
+		return src.Pix, src.Stride
 	case *image.RGBA:
-		pix = src.Pix
-		stride = src.Stride
-	default:
-		// Reuse NRGBA buffer if large enough
-		if e.nrgba == nil || e.nrgba.Bounds().Dx() < width || e.nrgba.Bounds().Dy() < height {
-			e.nrgba = image.NewNRGBA(bounds)
-		} else {
-			e.nrgba.Rect = bounds
-		}
-		draw.Draw(e.nrgba, bounds, img, bounds.Min, draw.Src)
-		pix = e.nrgba.Pix
-		stride = e.nrgba.Stride
+		return src.Pix, src.Stride
+	}
+
+	// Reuse NRGBA buffer if large enough
+	bounds := img.Bounds()
+	if e.nrgba == nil || e.nrgba.Bounds().Dx() < bounds.Dx() || e.nrgba.Bounds().Dy() < bounds.Dy() {
+		e.nrgba = image.NewNRGBA(bounds)
+	} else {
+		e.nrgba.Rect = bounds
 	}
+	draw.Draw(e.nrgba, bounds, img, bounds.Min, draw.Src)
+	return e.nrgba.Pix, e.nrgba.Stride
+}
+
+// computePackedFactors fills e.factors with the DCT factors of the
+// packed pixels in pix, as computeFactors does. Components and profile
+// must already have been checked.
+func (e *Encoder) computePackedFactors(xComponents, yComponents int, pix []uint8, stride int, layout packedLayout, width, height int, downsample bool) {
+	// Ensure buffers are large enough
+	e.maybeGrowBuffers(width, height, xComponents, yComponents)
 
 	// Compute cosine tables into reusable buffers
-	for i := 0; i < xComponents; i++ {
-		for x := 0; x < width; x++ {
-			e.cosX[i*width+x] = math.Cos(math.Pi * float64(i) * float64(x) / float64(width))
-		}
+	if e.Profile == ProfileReference {
+		fillReferenceCosines(e.cosX, xComponents, width, false)
+		fillReferenceCosines(e.cosY, yComponents, height, false)
+	} else {
+		fillCosines(e.cosX, xComponents, width, e.Deterministic)
+		fillCosines(e.cosY, yComponents, height, e.Deterministic)
 	}
-	for j := 0; j < yComponents; j++ {
-		for y := 0; y < height; y++ {
-			e.cosY[j*height+y] = math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
-		}
+
+	if downsample {
+		e.downsample(pix, stride, layout, width, height)
 	}
 
-	// Compute DCT factors
+	e.multiplyFactors(xComponents, yComponents, pix, stride, layout, width, height)
+}
+
+// multiplyFactors fills e.factors with the DCT factors of the packed
+// pixels in pix using the cosine tables in e.cosX and e.cosY, which it
+// only reads.
+func (e *Encoder) multiplyFactors(xComponents, yComponents int, pix []uint8, stride int, layout packedLayout, width, height int) {
 	for j := 0; j < yComponents; j++ {
 		for i := 0; i < xComponents; i++ {
 			cosXSlice := e.cosX[i*width : i*width+width]
 			cosYSlice := e.cosY[j*height : j*height+height]
-			factor := multiplyBasisFunction(i, j, pix, stride, cosXSlice, cosYSlice)
-			e.factors[j*xComponents+i] = factor
+			if e.Profile == ProfileReference {
+				e.factors[j*xComponents+i] = referenceMultiplyBasisFunction(i, j, pix, stride, layout, cosXSlice, cosYSlice)
+			} else {
+				e.factors[j*xComponents+i] = multiplyBasisFunction(i, j, pix, stride, layout, cosXSlice, cosYSlice)
+			}
 		}
 	}
+}
 
-	maximumValue := 0.0
+// writeHash quantises the given DCT factors with the standard rounding
+// rule and returns them as a blurhash.
+func (e *Encoder) writeHash(xComponents, yComponents int, factors [][3]float64) (string, error) {
+	return e.writeQuantised(e.quantise(xComponents, yComponents, factors))
+}
+
+// quantiseOptimised quantises the given DCT factors into e.quant using the
+// encoder's Optimise mode.
+func (e *Encoder) quantiseOptimised(xComponents, yComponents int, factors [][3]float64) *quantHash {
+	q := e.quantise(xComponents, yComponents, factors)
+	switch e.Optimise {
+	case OptimiseLinear:
+		optimiseLinear(q, factors)
+	case OptimiseSRGB, OptimiseOklab:
+		e.quantStd.xComponents, e.quantStd.yComponents = q.xComponents, q.yComponents
+		e.quantStd.maximum = q.maximum
+		e.quantStd.values = append(e.quantStd.values[:0], q.values...)
+		optimiseLinear(q, factors)
+		e.optimiseGrid(q, &e.quantStd, factors, e.Optimise.space())
+	}
+	return q
+}
+
+// quantise quantises the given DCT factors into e.quant using the
+// standard rounding rule.
+func (e *Encoder) quantise(xComponents, yComponents int, factors [][3]float64) *quantHash {
+	q := &e.quant
+	q.xComponents, q.yComponents = xComponents, yComponents
+	q.values = growTo(q.values, xComponents*yComponents)
+
+	maximumValue := 1.0
+	q.maximum = 0
 	if xComponents*yComponents-1 > 0 {
 		actualMaximumValue := 0.0
-		for j := 0; j < yComponents; j++ {
-			for i := 0; i < xComponents; i++ {
-				if j == 0 && i == 0 {
-					continue
-				}
-				f := e.factors[j*xComponents+i]
-				actualMaximumValue = math.Max(math.Abs(f[0]), actualMaximumValue)
-				actualMaximumValue = math.Max(math.Abs(f[1]), actualMaximumValue)
-				actualMaximumValue = math.Max(math.Abs(f[2]), actualMaximumValue)
-			}
+		for _, f := range factors[1:] {
+			actualMaximumValue = math.Max(e.Profile.magnitude(f[0]), actualMaximumValue)
+			actualMaximumValue = math.Max(e.Profile.magnitude(f[1]), actualMaximumValue)
+			actualMaximumValue = math.Max(e.Profile.magnitude(f[2]), actualMaximumValue)
 		}
 
-		quantisedMaximumValue := math.Max(0, math.Min(82, math.Floor(actualMaximumValue*166-0.5)))
+		quantisedMaximumValue := math.Max(0, math.Min(82, math.Floor(float64(actualMaximumValue*166)-0.5)))
 		maximumValue = (quantisedMaximumValue + 1) / 166
-		str, err := base83.Encode(int(quantisedMaximumValue), 1)
-		if err != nil {
-			return "", err
-		}
-		e.builder.WriteString(str)
-	} else {
-		maximumValue = 1
-		str, err := base83.Encode(0, 1)
-		if err != nil {
-			return "", err
+		q.maximum = int(quantisedMaximumValue)
+	}
+
+	dc := factors[0]
+	q.values[0] = [3]int{e.Profile.linearToSRGB(dc[0]), e.Profile.linearToSRGB(dc[1]), e.Profile.linearToSRGB(dc[2])}
+	for k, f := range factors[1:] {
+		q.values[k+1] = [3]int{
+			quantiseAC(f[0], maximumValue),
+			quantiseAC(f[1], maximumValue),
+			quantiseAC(f[2], maximumValue),
 		}
-		e.builder.WriteString(str)
 	}
+	return q
+}
 
-	dc := e.factors[0]
-	str, err := base83.Encode(encodeDC(dc[0], dc[1], dc[2]), 4)
+// writeQuantised returns q serialised as a blurhash.
+func (e *Encoder) writeQuantised(q *quantHash) (string, error) {
+	// Reset builder for new encode
+	e.builder.Reset()
+
+	sizeFlag := (q.xComponents - 1) + (q.yComponents-1)*9
+	str, err := base83.Encode(sizeFlag, 1)
 	if err != nil {
 		return "", err
 	}
 	e.builder.WriteString(str)
 
-	for j := 0; j < yComponents; j++ {
-		for i := 0; i < xComponents; i++ {
-			if j == 0 && i == 0 {
-				continue
-			}
-			f := e.factors[j*xComponents+i]
-			str, err := base83.Encode(encodeAC(f[0], f[1], f[2], maximumValue), 2)
-			if err != nil {
-				return "", err
-			}
-			e.builder.WriteString(str)
+	str, err = base83.Encode(q.maximum, 1)
+	if err != nil {
+		return "", err
+	}
+	e.builder.WriteString(str)
+
+	dc := q.values[0]
+	str, err = base83.Encode((dc[0]<<16)+(dc[1]<<8)+dc[2], 4)
+	if err != nil {
+		return "", err
+	}
+	e.builder.WriteString(str)
+
+	for _, v := range q.values[1:] {
+		str, err := base83.Encode(v[0]*19*19+v[1]*19+v[2], 2)
+		if err != nil {
+			return "", err
 		}
+		e.builder.WriteString(str)
 	}
 
 	return e.builder.String(), nil
 }
 
+// checkComponents returns ErrInvalidComponents if either component count is out of range.
+func checkComponents(xComponents, yComponents int) error {
+	if xComponents < minComponents || xComponents > maxComponents ||
+		yComponents < minComponents || yComponents > maxComponents {
+		return fmt.Errorf("%w: had x=%d, y=%d", ErrInvalidComponents, xComponents, yComponents)
+	}
+	return nil
+}
+
 func (e *Encoder) maybeGrowBuffers(width, height, xComponents, yComponents int) {
 	e.cosX = growTo(e.cosX, xComponents*width)
 	e.cosY = growTo(e.cosY, yComponents*height)
@@ -178,19 +279,26 @@ func Encode(xComponents, yComponents int, img image.Image) (string, error) {
 	return e.Encode(xComponents, yComponents, img)
 }
 
-func encodeDC(r, g, b float64) int {
-	return (linearToSRGB(r) << 16) + (linearToSRGB(g) << 8) + linearToSRGB(b)
+// quantiseAC returns the standard 0-18 code for a single AC channel value.
+func quantiseAC(v, maximumValue float64) int {
+	return int(math.Max(0, math.Min(18, math.Floor(float64(signSqrt(v/maximumValue)*9)+9.5))))
 }
 
-func encodeAC(r, g, b, maximumValue float64) int {
-	quantR := math.Max(0, math.Min(18, math.Floor(signSqrt(r/maximumValue)*9+9.5)))
-	quantG := math.Max(0, math.Min(18, math.Floor(signSqrt(g/maximumValue)*9+9.5)))
-	quantB := math.Max(0, math.Min(18, math.Floor(signSqrt(b/maximumValue)*9+9.5)))
-
-	return int(quantR*19*19 + quantG*19 + quantB)
+// packedLayout locates the colour channels of each pixel in a buffer of
+// packed 8-bit pixels.
+type packedLayout struct {
+	size    int // bytes per pixel
+	r, g, b int // offsets of the channels within a pixel
 }
 
-func multiplyBasisFunction(xComp, yComp int, pix []uint8, stride int, cosX, cosY []float64) [3]float64 {
+// layoutRGBA is the layout of image.NRGBA and image.RGBA pixels, and
+// layoutRGB of tightly packed RGB triples.
+var (
+	layoutRGBA = packedLayout{size: 4, r: 0, g: 1, b: 2}
+	layoutRGB  = packedLayout{size: 3, r: 0, g: 1, b: 2}
+)
+
+func multiplyBasisFunction(xComp, yComp int, pix []uint8, stride int, layout packedLayout, cosX, cosY []float64) [3]float64 {
 	var r, g, b float64
 	width, height := len(cosX), len(cosY)
 
@@ -203,11 +311,13 @@ func multiplyBasisFunction(xComp, yComp int, pix []uint8, stride int, cosX, cosY
 		rowOffset := y * stride
 		basisY := cosY[y]
 		for x := 0; x < width; x++ {
-			i := rowOffset + x*4
+			i := rowOffset + x*layout.size
 			basis := cosX[x] * basisY
-			r += basis * sRGBToLinear(int(pix[i]))
-			g += basis * sRGBToLinear(int(pix[i+1]))
-			b += basis * sRGBToLinear(int(pix[i+2]))
+			// Explicit conversions prevent fused multiply-adds, which
+			// would make results differ between architectures.
+			r += float64(basis * sRGBToLinear(int(pix[i+layout.r])))
+			g += float64(basis * sRGBToLinear(int(pix[i+layout.g])))
+			b += float64(basis * sRGBToLinear(int(pix[i+layout.b])))
 		}
 	}
 
@@ -11,4 +11,20 @@ var (
 	ErrInvalidHash = errors.New("blurhash: invalid hash")
 	// ErrInvalidDimensions is returned when width or height is invalid.
 	ErrInvalidDimensions = errors.New("blurhash: width and height must be positive")
+	// ErrInvalidStream is returned when a frame stream is malformed or unsupported.
+	ErrInvalidStream = errors.New("blurhash: invalid frame stream")
+	// ErrInvalidProfile is returned when an Encoder or Decoder has an unknown Profile.
+	ErrInvalidProfile = errors.New("blurhash: unknown algorithm profile")
+	// ErrInvalidDither is returned when a Decoder has an unknown Dither mode.
+	ErrInvalidDither = errors.New("blurhash: unknown dither mode")
+	// ErrInvalidApproximation is returned when a Decoder has an invalid Approximation.
+	ErrInvalidApproximation = errors.New("blurhash: invalid approximation")
+	// ErrInvalidPixelFormat is returned when DecodeBuffer is given an unknown PixelFormat.
+	ErrInvalidPixelFormat = errors.New("blurhash: unknown pixel format")
+	// ErrInvalidDetail is returned when a Decoder has an invalid LevelOfDetail.
+	ErrInvalidDetail = errors.New("blurhash: invalid level of detail")
+	// ErrInvalidFit is returned when a Decoder has an invalid Fit.
+	ErrInvalidFit = errors.New("blurhash: invalid fit")
+	// ErrInvalidComposite is returned when DecodeComposite is given an invalid Composite.
+	ErrInvalidComposite = errors.New("blurhash: invalid composite")
 )
@@ -0,0 +1,12 @@
+package blurhash
+
+// SetCacheDecodeHook sets a function called by every decode of a Cache and
+// returns a function restoring the previous one.
+func SetCacheDecodeHook(f func()) (restore func()) {
+	old := testHookCacheDecode
+	testHookCacheDecode = f
+	return func() { testHookCacheDecode = old }
+}
+
+// LoadFixture decodes the image in a test fixture file.
+var LoadFixture = loadFixture
@@ -0,0 +1,193 @@
+package blurhash
+
+import "math"
+
+// This file ports the cosine of fdlibm 5.3, which V8 uses for Math.cos,
+// so ProfileReference can reproduce the TypeScript reference
+// implementation bit for bit.
+//
+// Every intermediate result is explicitly converted to float64, which
+// prevents the compiler fusing multiply-adds and keeps results identical
+// on every GOARCH. Only arguments below 2^19·π/2 are supported, which
+// covers every angle blurhash uses.
+
+// highWord returns the upper 32 bits of the representation of x.
+func highWord(x float64) int32 {
+	return int32(math.Float64bits(x) >> 32)
+}
+
+// fromWords returns the float64 with the given upper and lower words.
+func fromWords(hi int32, lo uint32) float64 {
+	return math.Float64frombits(uint64(uint32(hi))<<32 | uint64(lo))
+}
+
+// fdlibmCos returns the cosine of x as computed by fdlibm.
+func fdlibmCos(x float64) float64 {
+	ix := highWord(x) & 0x7fffffff
+	if ix <= 0x3fe921fb {
+		return kernelCos(x, 0)
+	}
+
+	n, y0, y1 := remPio2(x)
+	switch n & 3 {
+	case 0:
+		return kernelCos(y0, y1)
+	case 1:
+		return -kernelSin(y0, y1)
+	case 2:
+		return -kernelCos(y0, y1)
+	default:
+		return kernelSin(y0, y1)
+	}
+}
+
+// kernelCos returns cos(x+y) for |x| <= π/4, where y is the tail of x.
+func kernelCos(x, y float64) float64 {
+	const (
+		c1 = 4.16666666666666019037e-02
+		c2 = -1.38888888888741095749e-03
+		c3 = 2.48015872894767294178e-05
+		c4 = -2.75573143513906633035e-07
+		c5 = 2.08757232129817482790e-09
+		c6 = -1.13596475577881948265e-11
+	)
+
+	ix := highWord(x) & 0x7fffffff
+	if ix < 0x3e400000 && int(x) == 0 {
+		return 1
+	}
+	z := float64(x * x)
+	r := float64(z * float64(c5+float64(z*c6)))
+	r = float64(z * float64(c4+r))
+	r = float64(z * float64(c3+r))
+	r = float64(z * float64(c2+r))
+	r = float64(z * float64(c1+r))
+	zrxy := float64(float64(z*r) - float64(x*y))
+	if ix < 0x3fd33333 {
+		return 1 - float64(float64(0.5*z)-zrxy)
+	}
+	qx := 0.28125
+	if ix <= 0x3fe90000 {
+		qx = fromWords(ix-0x00200000, 0)
+	}
+	iz := float64(float64(0.5*z) - qx)
+	a := 1 - qx
+	return a - float64(iz-zrxy)
+}
+
+// kernelSin returns sin(x+y) for |x| <= π/4, where y is the tail of x.
+func kernelSin(x, y float64) float64 {
+	const (
+		s1 = -1.66666666666666324348e-01
+		s2 = 8.33333333332248946124e-03
+		s3 = -1.98412698298579493134e-04
+		s4 = 2.75573137070700676789e-06
+		s5 = -2.50507602534068634195e-08
+		s6 = 1.58969099521155010221e-10
+	)
+
+	ix := highWord(x) & 0x7fffffff
+	if ix < 0x3e400000 && int(x) == 0 {
+		return x
+	}
+	z := float64(x * x)
+	v := float64(z * x)
+	r := float64(z * float64(s5+float64(z*s6)))
+	r = float64(z * float64(s4+r))
+	r = float64(z * float64(s3+r))
+	r = s2 + r
+	t := float64(float64(z*float64(float64(0.5*y)-float64(v*r))) - y)
+	return x - float64(t-float64(v*s1))
+}
+
+// npio2HighWords holds the high words of n·π/2 for n in [1, 32].
+var npio2HighWords = [32]int32{
+	0x3ff921fb, 0x400921fb, 0x4012d97c, 0x401921fb, 0x401f6a7a, 0x4022d97c,
+	0x4025fdbb, 0x402921fb, 0x402c463a, 0x402f6a7a, 0x4031475c, 0x4032d97c,
+	0x40346b9c, 0x4035fdbb, 0x40378fdb, 0x403921fb, 0x403ab41b, 0x403c463a,
+	0x403dd85a, 0x403f6a7a, 0x40407e4c, 0x4041475c, 0x4042106c, 0x4042d97c,
+	0x4043a28c, 0x40446b9c, 0x404534ac, 0x4045fdbb, 0x4046c6cb, 0x40478fdb,
+	0x404858eb, 0x404921fb,
+}
+
+// remPio2 reduces x to y0+y1 in [-π/4, π/4], returning the multiple n of
+// π/2 subtracted from x.
+func remPio2(x float64) (n int, y0, y1 float64) {
+	const (
+		invpio2 = 6.36619772367581382433e-01
+		pio2_1  = 1.57079632673412561417e+00
+		pio2_1t = 6.07710050650619224932e-11
+		pio2_2  = 6.07710050630396597660e-11
+		pio2_2t = 2.02226624879595063154e-21
+		pio2_3  = 2.02226624871116645580e-21
+		pio2_3t = 8.47842766036889956997e-32
+	)
+
+	hx := highWord(x)
+	ix := hx & 0x7fffffff
+	if ix <= 0x3fe921fb {
+		return 0, x, 0
+	}
+	if ix < 0x4002d97c {
+		// |x| < 3π/4, so n is ±1.
+		if hx > 0 {
+			z := x - pio2_1
+			if ix != 0x3ff921fb {
+				y0 = z - pio2_1t
+				y1 = float64(z-y0) - pio2_1t
+			} else {
+				z -= pio2_2
+				y0 = z - pio2_2t
+				y1 = float64(z-y0) - pio2_2t
+			}
+			return 1, y0, y1
+		}
+		z := x + pio2_1
+		if ix != 0x3ff921fb {
+			y0 = z + pio2_1t
+			y1 = float64(z-y0) + pio2_1t
+		} else {
+			z += pio2_2
+			y0 = z + pio2_2t
+			y1 = float64(z-y0) + pio2_2t
+		}
+		return -1, y0, y1
+	}
+	if ix > 0x413921fb {
+		// Beyond the medium range, which blurhash never reaches.
+		panic("blurhash: fdlibm argument out of range")
+	}
+
+	t := math.Abs(x)
+	n = int(float64(t*invpio2) + 0.5)
+	fn := float64(n)
+	r := t - float64(fn*pio2_1)
+	w := float64(fn * pio2_1t)
+	if n < 32 && ix != npio2HighWords[n-1] {
+		y0 = r - w
+	} else {
+		j := ix >> 20
+		y0 = r - w
+		i := j - (highWord(y0)>>20)&0x7ff
+		if i > 16 {
+			t = r
+			w = float64(fn * pio2_2)
+			r = t - w
+			w = float64(fn*pio2_2t) - float64(float64(t-r)-w)
+			y0 = r - w
+			i = j - (highWord(y0)>>20)&0x7ff
+			if i > 49 {
+				t = r
+				w = float64(fn * pio2_3)
+				r = t - w
+				w = float64(fn*pio2_3t) - float64(float64(t-r)-w)
+				y0 = r - w
+			}
+		}
+	}
+	y1 = float64(r-y0) - w
+	if hx < 0 {
+		return -n, -y0, -y1
+	}
+	return n, y0, y1
+}
@@ -0,0 +1,230 @@
+package blurhash
+
+import (
+	"fmt"
+	"math"
+)
+
+// FitMode selects how a hash is fitted to an image whose aspect ratio
+// differs from that of its source, as the CSS object-fit property does.
+type FitMode int
+
+const (
+	// FitFill stretches the hash to the image, distorting it if the
+	// aspect ratios differ. This is how blurhash is usually decoded.
+	FitFill FitMode = iota
+
+	// FitCover scales the hash to cover the image, preserving the aspect
+	// ratio of the source and cropping whatever overflows.
+	FitCover
+
+	// FitContain scales the hash to fit inside the image, preserving the
+	// aspect ratio of the source and filling the rest with a border.
+	FitContain
+)
+
+// String returns the name of the mode.
+func (m FitMode) String() string {
+	switch m {
+	case FitFill:
+		return "fill"
+	case FitCover:
+		return "cover"
+	case FitContain:
+		return "contain"
+	}
+	return fmt.Sprintf("FitMode(%d)", int(m))
+}
+
+// FitBorder selects how FitContain fills the image beyond the hash.
+type FitBorder int
+
+const (
+	// BorderExtend repeats the outermost pixels of the hash.
+	BorderExtend FitBorder = iota
+
+	// BorderDC fills the border with the average colour of the hash.
+	BorderDC
+)
+
+// String returns the name of the border.
+func (b FitBorder) String() string {
+	switch b {
+	case BorderExtend:
+		return "extend"
+	case BorderDC:
+		return "dc"
+	}
+	return fmt.Sprintf("FitBorder(%d)", int(b))
+}
+
+// Fit places a hash in an image as the CSS object-fit and object-position
+// properties place a replaced element in its box.
+//
+// The zero value stretches the hash to the image.
+type Fit struct {
+	// Mode selects how the hash is scaled.
+	Mode FitMode
+
+	// SourceWidth and SourceHeight give the aspect ratio of the image the
+	// hash was encoded from, in any units. If both are zero, or the
+	// aspect ratio is that of the decoded image, the hash fills it.
+	SourceWidth, SourceHeight int
+
+	// FocusX and FocusY position the focal point of the hash relative to
+	// the centre of its source, from -0.5 at its left or top edge to 0.5
+	// at its right or bottom edge. As with object-position, that point of
+	// the hash is aligned with the same point of the image, so a focus of
+	// -0.5 is object-position: left and the zero value centres the hash.
+	FocusX, FocusY float64
+
+	// Border selects how FitContain fills the image beyond the hash.
+	Border FitBorder
+}
+
+// check returns an error if f has an unknown mode or border, an invalid
+// source size or a focus beyond the source.
+func (f Fit) check() error {
+	if f.Mode < FitFill || f.Mode > FitContain {
+		return fmt.Errorf("%w: %v", ErrInvalidFit, f.Mode)
+	}
+	if f.Border < BorderExtend || f.Border > BorderDC {
+		return fmt.Errorf("%w: %v", ErrInvalidFit, f.Border)
+	}
+	if f.SourceWidth < 0 || f.SourceHeight < 0 || (f.SourceWidth == 0) != (f.SourceHeight == 0) {
+		return fmt.Errorf("%w: had source width=%d, height=%d", ErrInvalidFit, f.SourceWidth, f.SourceHeight)
+	}
+	if !(f.FocusX >= -0.5 && f.FocusX <= 0.5 && f.FocusY >= -0.5 && f.FocusY <= 0.5) {
+		return fmt.Errorf("%w: had focus x=%v, y=%v", ErrInvalidFit, f.FocusX, f.FocusY)
+	}
+	return nil
+}
+
+// fitAxis maps the pixels along one axis of an image to positions in the
+// hash.
+type fitAxis struct {
+	// n is the number of pixels along the axis.
+	n int
+	// identity is set when the hash spans the axis exactly.
+	identity bool
+	// size and offset are the extent and start of the hash in pixels.
+	size, offset float64
+	// lo and hi are the first and last pixels that show the hash. Pixels
+	// beyond them take the position of the nearer one.
+	lo, hi int
+}
+
+// newFitAxis returns the mapping of an axis of n pixels along which the
+// hash has the given size, placed by focus.
+func newFitAxis(n int, size, focus float64) fitAxis {
+	if size == float64(n) {
+		return fitAxis{n: n, identity: true, size: size, hi: n - 1}
+	}
+	// Explicit conversions prevent fused multiply-adds.
+	offset := float64((float64(n) - size) * (0.5 + focus))
+	lo := minInt(maxInt(int(math.Ceil(offset)), 0), n-1)
+	hi := minInt(maxInt(int(math.Ceil(offset+size))-1, lo), n-1)
+	return fitAxis{n: n, size: size, offset: offset, lo: lo, hi: hi}
+}
+
+// shows reports whether pixel p shows the hash rather than a border.
+func (a fitAxis) shows(p int) bool {
+	return p >= a.lo && p <= a.hi
+}
+
+// position returns the position of pixel p in the hash, from 0 to 1.
+func (a fitAxis) position(p int) float64 {
+	p = minInt(maxInt(p, a.lo), a.hi)
+	return (float64(p) - a.offset) / a.size
+}
+
+// axes returns the mappings of the axes of an image of the given size.
+func (f Fit) axes(width, height int) (x, y fitAxis) {
+	x = newFitAxis(width, float64(width), 0)
+	y = newFitAxis(height, float64(height), 0)
+	if f.Mode == FitFill || f.SourceWidth == 0 {
+		return x, y
+	}
+
+	// Compare the aspect ratios exactly, so the axis the hash spans is
+	// always mapped as the hash is usually decoded.
+	boxAspect, sourceAspect := width*f.SourceHeight, f.SourceWidth*height
+	if boxAspect == sourceAspect {
+		return x, y
+	}
+	if (boxAspect > sourceAspect) == (f.Mode == FitCover) {
+		// The hash spans the width and overflows or underfills the height.
+		y = newFitAxis(height, float64(width)*float64(f.SourceHeight)/float64(f.SourceWidth), f.FocusY)
+	} else {
+		x = newFitAxis(width, float64(height)*float64(f.SourceWidth)/float64(f.SourceHeight), f.FocusX)
+	}
+	return x, y
+}
+
+// dcBorder reports whether the pixels of an image beyond the hash, as
+// mapped by x and y, take its DC colour.
+func (f Fit) dcBorder(x, y fitAxis) bool {
+	return f.Mode == FitContain && f.Border == BorderDC && !(x.identity && y.identity)
+}
+
+// prepareFit maps the axes of an image of the given size for d.Fit,
+// reporting whether either differs from the usual mapping.
+func (d *Decoder) prepareFit(width, height int) bool {
+	d.fitX, d.fitY = d.Fit.axes(width, height)
+	d.fitted = !d.fitX.identity || !d.fitY.identity
+	d.fitBorder = d.Fit.dcBorder(d.fitX, d.fitY)
+	return d.fitted
+}
+
+// fillAxisCosines fills table with the basis functions of the given
+// number of components at each pixel of the axis a, using the decoder's
+// Profile and Deterministic options. Unmapped axes are filled exactly as
+// for an unfitted decode.
+func (d *Decoder) fillAxisCosines(table []float64, components int, a fitAxis) {
+	n := a.n
+	if a.identity {
+		if d.Profile == ProfileReference {
+			fillReferenceCosines(table, components, n, true)
+		} else {
+			fillCosines(table, components, n, d.Deterministic)
+		}
+		return
+	}
+	for p := 0; p < n; p++ {
+		u := a.position(p)
+		for i := 0; i < components; i++ {
+			angle := float64(math.Pi*float64(i)) * u
+			if d.Deterministic {
+				// fdlibmCos is bit-identical on every GOARCH, and the
+				// positions are correctly rounded.
+				table[i*n+p] = fdlibmCos(angle)
+			} else {
+				table[i*n+p] = math.Cos(angle)
+			}
+		}
+	}
+}
+
+// fillBorder overwrites the pixels of row y beyond the hash with its DC
+// colour, in w.linear for 16-bit writers and w.row otherwise.
+func (d *Decoder) fillBorder(w *rowWorker, y, width int) {
+	rowShows := d.fitY.shows(y)
+	dc := d.colors[0]
+	level := [3]uint8{
+		uint8(d.Profile.linearToSRGB(dc[0])),
+		uint8(d.Profile.linearToSRGB(dc[1])),
+		uint8(d.Profile.linearToSRGB(dc[2])),
+	}
+	for x := 0; x < width; x++ {
+		if rowShows && d.fitX.shows(x) {
+			continue
+		}
+		for c := 0; c < 3; c++ {
+			if w.wide {
+				w.linear[x*3+c] = dc[c]
+			} else {
+				w.row[x*3+c] = level[c]
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package blurhash_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func decodeFit(t *testing.T, dec blurhash.Decoder, hash string, width, height int) *image.NRGBA {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if err := dec.DecodeDraw(img, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	return img
+}
+
+// assertRegion checks that the pixels of got from origin match want to
+// within tol levels.
+func assertRegion(t *testing.T, got *image.NRGBA, origin image.Point, want *image.NRGBA, tol int) {
+	t.Helper()
+	b := want.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			g, w := got.NRGBAAt(origin.X+x, origin.Y+y), want.NRGBAAt(x, y)
+			if absDiff(g.R, w.R) > tol || absDiff(g.G, w.G) > tol || absDiff(g.B, w.B) > tol || g.A != w.A {
+				t.Fatalf("pixel (%d, %d) is %v, want %v", origin.X+x, origin.Y+y, g, w)
+			}
+		}
+	}
+}
+
+func TestFitUnmapped(t *testing.T) {
+	hash := testFixtures[0].hash
+	want := decodeFit(t, blurhash.Decoder{}, hash, 48, 32)
+	for _, fit := range []blurhash.Fit{
+		{Mode: blurhash.FitFill, SourceWidth: 10, SourceHeight: 20},
+		{Mode: blurhash.FitCover},
+		{Mode: blurhash.FitCover, SourceWidth: 3, SourceHeight: 2, FocusX: 0.5},
+		{Mode: blurhash.FitContain, SourceWidth: 300, SourceHeight: 200, Border: blurhash.BorderDC},
+	} {
+		got := decodeFit(t, blurhash.Decoder{Fit: fit}, hash, 48, 32)
+		assertRegion(t, got, image.Point{}, want, 0)
+	}
+}
+
+func TestFitCover(t *testing.T) {
+	hash := testFixtures[1].hash
+	// A square source covering a 64x32 image shows a band of the 64x64
+	// decode, placed by the focus.
+	square := decodeFit(t, blurhash.Decoder{}, hash, 64, 64)
+	for _, test := range []struct {
+		focus float64
+		top   int
+	}{
+		{0, 16},
+		{-0.5, 0},
+		{0.5, 32},
+	} {
+		dec := blurhash.Decoder{Fit: blurhash.Fit{Mode: blurhash.FitCover, SourceWidth: 5, SourceHeight: 5, FocusY: test.focus}}
+		got := decodeFit(t, dec, hash, 64, 32)
+		want := square.SubImage(image.Rect(0, test.top, 64, test.top+32)).(*image.NRGBA)
+		assertRegion(t, got, image.Point{0, -test.top}, want, 1)
+	}
+}
+
+func TestFitContain(t *testing.T) {
+	hash := testFixtures[1].hash
+	square := decodeFit(t, blurhash.Decoder{}, hash, 32, 32)
+	dc := decodeFit(t, blurhash.Decoder{Detail: blurhash.LevelOfDetail{Components: 1}}, hash, 1, 1).NRGBAAt(0, 0)
+
+	fit := blurhash.Fit{Mode: blurhash.FitContain, SourceWidth: 1, SourceHeight: 1}
+	extended := decodeFit(t, blurhash.Decoder{Fit: fit}, hash, 64, 32)
+	fit.Border = blurhash.BorderDC
+	bordered := decodeFit(t, blurhash.Decoder{Fit: fit}, hash, 64, 32)
+
+	// The hash is centred in both, as a 32x32 decode.
+	assertRegion(t, extended, image.Point{16, 0}, square, 1)
+	assertRegion(t, bordered, image.Point{16, 0}, square, 0)
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 64; x++ {
+			if x >= 16 && x < 48 {
+				continue
+			}
+			edge := 16
+			if x >= 48 {
+				edge = 47
+			}
+			if c, w := extended.NRGBAAt(x, y), extended.NRGBAAt(edge, y); c != w {
+				t.Fatalf("extended border pixel (%d, %d) is %v, want %v", x, y, c, w)
+			}
+			if c := bordered.NRGBAAt(x, y); c != dc {
+				t.Fatalf("DC border pixel (%d, %d) is %v, want %v", x, y, c, dc)
+			}
+		}
+	}
+}
+
+func TestFitOptions(t *testing.T) {
+	hash := testFixtures[2].hash
+	fits := []blurhash.Fit{
+		{Mode: blurhash.FitCover, SourceWidth: 4, SourceHeight: 3, FocusX: 0.3},
+		{Mode: blurhash.FitContain, SourceWidth: 3, SourceHeight: 7, FocusX: -0.2},
+		{Mode: blurhash.FitContain, SourceWidth: 16, SourceHeight: 3, FocusY: 0.5, Border: blurhash.BorderDC},
+	}
+	for _, fit := range fits {
+		want := decodeFit(t, blurhash.Decoder{Fit: fit}, hash, 37, 23)
+
+		// Deterministic cosines and approximation barely change fitted
+		// decodes. Approximation is not applied to them.
+		got := decodeFit(t, blurhash.Decoder{Fit: fit, Deterministic: true}, hash, 37, 23)
+		assertRegion(t, got, image.Point{}, want, 1)
+		approx := blurhash.Approximation{Interpolation: blurhash.InterpolateBilinear, Samples: 1}
+		got = decodeFit(t, blurhash.Decoder{Fit: fit, Approximation: approx}, hash, 37, 23)
+		assertRegion(t, got, image.Point{}, want, 0)
+
+		// Placeholders fit the hash identically.
+		for _, dec := range []blurhash.Decoder{{Fit: fit}, {Fit: fit, Profile: blurhash.ProfileReference}} {
+			want := decodeFit(t, dec, hash, 37, 23)
+			p, err := dec.Placeholder(hash, 37, 23, 1)
+			if err != nil {
+				t.Fatalf("error creating placeholder: %v", err)
+			}
+			for y := 0; y < 23; y++ {
+				for x := 0; x < 37; x++ {
+					if c, w := p.NRGBAAt(x, y), want.NRGBAAt(x, y); c != w {
+						t.Fatalf("%+v: placeholder pixel (%d, %d) is %v, want %v", fit, x, y, c, w)
+					}
+				}
+			}
+		}
+
+		// 16-bit decodes fit the hash as 8-bit ones do.
+		wide := image.NewRGBA64(image.Rect(0, 0, 37, 23))
+		dec := blurhash.Decoder{Fit: fit, HighBitDepth: true}
+		if err := dec.DecodeDraw(wide, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		for y := 0; y < 23; y++ {
+			for x := 0; x < 37; x++ {
+				c := color.NRGBAModel.Convert(wide.At(x, y)).(color.NRGBA)
+				w := want.NRGBAAt(x, y)
+				if absDiff(c.R, w.R) > 1 || absDiff(c.G, w.G) > 1 || absDiff(c.B, w.B) > 1 {
+					t.Fatalf("%+v: 16-bit pixel (%d, %d) is %v, want %v", fit, x, y, c, w)
+				}
+			}
+		}
+	}
+}
+
+func TestFitInvalid(t *testing.T) {
+	hash := testFixtures[0].hash
+	for _, fit := range []blurhash.Fit{
+		{Mode: 3},
+		{Border: -1},
+		{SourceWidth: 4},
+		{SourceWidth: -4, SourceHeight: 3},
+		{FocusX: 0.6},
+		{FocusY: math.NaN()},
+	} {
+		dec := blurhash.Decoder{Fit: fit}
+		if _, err := dec.Decode(hash, 8, 8, 1); !errors.Is(err, blurhash.ErrInvalidFit) {
+			t.Errorf("%+v: expected %v, got %v", fit, blurhash.ErrInvalidFit, err)
+		}
+		if _, err := dec.Placeholder(hash, 8, 8, 1); !errors.Is(err, blurhash.ErrInvalidFit) {
+			t.Errorf("%+v: expected %v, got %v", fit, blurhash.ErrInvalidFit, err)
+		}
+	}
+}
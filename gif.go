@@ -0,0 +1,237 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"sort"
+)
+
+// GIFHashes holds the blurhashes of an animated GIF.
+type GIFHashes struct {
+	// Frames holds the hash of each frame, composited as it would be displayed.
+	Frames []string
+	// Delay holds the delay of each frame in 100ths of a second.
+	Delay []int
+	// LoopCount is copied from the source GIF. See gif.GIF for its meaning.
+	LoopCount int
+	// Representative is the hash of the time-weighted average of the
+	// coefficients of all frames.
+	Representative string
+}
+
+// shortGIFDelay is the delay, in 100ths of a second, used for frames whose
+// declared delay is 0 or 1. Browsers display such frames for this long,
+// but honour longer delays as declared.
+const shortGIFDelay = 10
+
+// EncodeGIF returns a hash for every frame of an animated GIF, plus a
+// representative hash for the whole animation.
+//
+// Frames are composited onto a canvas honouring each frame's disposal
+// method and transparency, so every hash represents what is on screen
+// while that frame is displayed rather than the raw frame data.
+func EncodeGIF(xComponents, yComponents int, g *gif.GIF) (GIFHashes, error) {
+	if err := checkComponents(xComponents, yComponents); err != nil {
+		return GIFHashes{}, err
+	}
+	if len(g.Image) == 0 {
+		return GIFHashes{}, fmt.Errorf("%w: gif has no frames", ErrInvalidStream)
+	}
+
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = image.Rectangle{}
+		for _, frame := range g.Image {
+			bounds = bounds.Union(frame.Bounds())
+		}
+	}
+
+	var e Encoder
+	canvas := image.NewRGBA(bounds)
+	var previous *image.RGBA
+	weighted := make([][3]float64, xComponents*yComponents)
+	var totalDelay float64
+
+	hashes := GIFHashes{
+		Frames:    make([]string, len(g.Image)),
+		Delay:     make([]int, len(g.Image)),
+		LoopCount: g.LoopCount,
+	}
+
+	for i, frame := range g.Image {
+		disposal := byte(gif.DisposalNone)
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+		if i < len(g.Delay) {
+			hashes.Delay[i] = g.Delay[i]
+		}
+
+		if disposal == gif.DisposalPrevious {
+			if previous == nil {
+				previous = image.NewRGBA(bounds)
+			}
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		if err := e.computeFactors(xComponents, yComponents, canvas, false); err != nil {
+			return GIFHashes{}, err
+		}
+		hash, err := e.writeHash(xComponents, yComponents, e.factors)
+		if err != nil {
+			return GIFHashes{}, err
+		}
+		hashes.Frames[i] = hash
+
+		delay := float64(hashes.Delay[i])
+		if delay <= 1 {
+			delay = shortGIFDelay
+		}
+		for k, f := range e.factors {
+			for c := 0; c < 3; c++ {
+				weighted[k][c] += f[c] * delay
+			}
+		}
+		totalDelay += delay
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			copy(canvas.Pix, previous.Pix)
+		}
+	}
+
+	for k := range weighted {
+		for c := 0; c < 3; c++ {
+			weighted[k][c] /= totalDelay
+		}
+	}
+	representative, err := e.writeHash(xComponents, yComponents, weighted)
+	if err != nil {
+		return GIFHashes{}, err
+	}
+	hashes.Representative = representative
+
+	return hashes, nil
+}
+
+// DecodeGIF renders the frame hashes of h into an animated placeholder GIF
+// of the given size, using the delays and loop count of h.
+func DecodeGIF(h GIFHashes, width, height int, punch float64) (*gif.GIF, error) {
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("%w: had width=%d, height=%d", ErrInvalidDimensions, width, height)
+	}
+	if len(h.Frames) == 0 {
+		return nil, fmt.Errorf("%w: no frame hashes to decode", ErrInvalidStream)
+	}
+
+	var d Decoder
+	rect := image.Rect(0, 0, width, height)
+	nrgba := image.NewNRGBA(rect)
+
+	g := &gif.GIF{
+		Image:     make([]*image.Paletted, len(h.Frames)),
+		Delay:     make([]int, len(h.Frames)),
+		Disposal:  make([]byte, len(h.Frames)),
+		LoopCount: h.LoopCount,
+		Config:    image.Config{Width: width, Height: height},
+	}
+	for i, hash := range h.Frames {
+		if err := d.DecodeDraw(nrgba, hash, punch); err != nil {
+			return nil, err
+		}
+		frame := image.NewPaletted(rect, medianCutPalette(nrgba.Pix, 256))
+		draw.FloydSteinberg.Draw(frame, rect, nrgba, image.Point{})
+		g.Image[i] = frame
+		if i < len(h.Delay) {
+			g.Delay[i] = h.Delay[i]
+		}
+		g.Disposal[i] = gif.DisposalNone
+	}
+	g.Config.ColorModel = g.Image[0].Palette
+	return g, nil
+}
+
+// medianCutPalette returns a palette of at most size colours representing
+// the RGB values of the pixels in pix, using the median cut algorithm.
+func medianCutPalette(pix []uint8, size int) color.Palette {
+	pixels := make([][3]uint8, 0, len(pix)/4)
+	for i := 0; i < len(pix); i += 4 {
+		pixels = append(pixels, [3]uint8{pix[i], pix[i+1], pix[i+2]})
+	}
+
+	boxes := []colorBox{newColorBox(pixels)}
+	for len(boxes) < size {
+		// Split the box with the widest channel range.
+		best := 0
+		for i, box := range boxes {
+			if box.spread > boxes[best].spread {
+				best = i
+			}
+		}
+		box := boxes[best]
+		if box.spread == 0 {
+			break
+		}
+
+		sort.Slice(box.pixels, func(a, b int) bool {
+			return box.pixels[a][box.channel] < box.pixels[b][box.channel]
+		})
+		mid := len(box.pixels) / 2
+		boxes[best] = newColorBox(box.pixels[:mid])
+		boxes = append(boxes, newColorBox(box.pixels[mid:]))
+	}
+
+	palette := make(color.Palette, 0, len(boxes))
+	for _, box := range boxes {
+		var sum [3]int
+		for _, p := range box.pixels {
+			sum[0] += int(p[0])
+			sum[1] += int(p[1])
+			sum[2] += int(p[2])
+		}
+		n := len(box.pixels)
+		palette = append(palette, color.RGBA{
+			uint8((sum[0] + n/2) / n),
+			uint8((sum[1] + n/2) / n),
+			uint8((sum[2] + n/2) / n),
+			255,
+		})
+	}
+	return palette
+}
+
+// colorBox is a set of pixels with the channel along which they vary most.
+type colorBox struct {
+	pixels  [][3]uint8
+	channel int
+	spread  int
+}
+
+func newColorBox(pixels [][3]uint8) colorBox {
+	box := colorBox{pixels: pixels}
+	if len(pixels) < 2 {
+		return box
+	}
+	for c := 0; c < 3; c++ {
+		lo, hi := pixels[0][c], pixels[0][c]
+		for _, p := range pixels {
+			if p[c] < lo {
+				lo = p[c]
+			}
+			if p[c] > hi {
+				hi = p[c]
+			}
+		}
+		if int(hi-lo) > box.spread {
+			box.channel, box.spread = c, int(hi-lo)
+		}
+	}
+	return box
+}
@@ -0,0 +1,232 @@
+package blurhash_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+var gifPalette = color.Palette{
+	color.RGBA{},
+	color.RGBA{255, 0, 0, 255},
+	color.RGBA{0, 0, 255, 255},
+	color.RGBA{0, 255, 0, 255},
+}
+
+func palettedFrame(r image.Rectangle, index uint8) *image.Paletted {
+	img := image.NewPaletted(r, gifPalette)
+	for i := range img.Pix {
+		img.Pix[i] = index
+	}
+	return img
+}
+
+func TestEncodeGIFCompositing(t *testing.T) {
+	full := image.Rect(0, 0, 16, 16)
+	patch := image.Rect(0, 0, 8, 8)
+	corner := image.Rect(12, 12, 16, 16)
+
+	// The third frame has a transparent left half, so the
+	// disposed region of the second frame shows through.
+	third := palettedFrame(corner, 3)
+	for y := corner.Min.Y; y < corner.Max.Y; y++ {
+		third.SetColorIndex(12, y, 0)
+		third.SetColorIndex(13, y, 0)
+	}
+
+	g := &gif.GIF{
+		Image:    []*image.Paletted{palettedFrame(full, 1), palettedFrame(patch, 2), third},
+		Delay:    []int{10, 10, 10},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalBackground, gif.DisposalNone},
+		Config:   image.Config{Width: 16, Height: 16},
+	}
+
+	// Build the expected on-screen canvas for each frame.
+	canvas := image.NewRGBA(full)
+	draw.Draw(canvas, full, &image.Uniform{gifPalette[1]}, image.Point{}, draw.Src)
+	want0 := cloneRGBA(canvas)
+	draw.Draw(canvas, patch, &image.Uniform{gifPalette[2]}, image.Point{}, draw.Src)
+	want1 := cloneRGBA(canvas)
+	draw.Draw(canvas, patch, image.Transparent, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rect(14, 12, 16, 16), &image.Uniform{gifPalette[3]}, image.Point{}, draw.Src)
+	want2 := cloneRGBA(canvas)
+
+	hashes, err := blurhash.EncodeGIF(4, 3, g)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	for i, want := range []*image.RGBA{want0, want1, want2} {
+		wantHash, err := blurhash.Encode(4, 3, want)
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if hashes.Frames[i] != wantHash {
+			t.Errorf("frame %d: hash mismatch: got %q, want %q", i, hashes.Frames[i], wantHash)
+		}
+	}
+}
+
+func TestEncodeGIFDisposalPrevious(t *testing.T) {
+	full := image.Rect(0, 0, 16, 16)
+	g := &gif.GIF{
+		Image: []*image.Paletted{
+			palettedFrame(full, 1),
+			palettedFrame(image.Rect(0, 0, 8, 8), 2),
+			palettedFrame(image.Rect(8, 8, 9, 9), 1),
+		},
+		Disposal: []byte{gif.DisposalNone, gif.DisposalPrevious, gif.DisposalNone},
+	}
+
+	hashes, err := blurhash.EncodeGIF(3, 3, g)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	// The second frame is restored away, and the third frame only
+	// redraws a pixel of the same colour, so frames 0 and 2 match.
+	if hashes.Frames[2] != hashes.Frames[0] {
+		t.Errorf("disposal previous should restore the canvas: got %q, want %q", hashes.Frames[2], hashes.Frames[0])
+	}
+	if hashes.Frames[1] == hashes.Frames[0] {
+		t.Error("second frame should differ from the first")
+	}
+}
+
+func TestEncodeGIFRepresentative(t *testing.T) {
+	full := image.Rect(0, 0, 8, 8)
+
+	t.Run("identical frames", func(t *testing.T) {
+		g := &gif.GIF{
+			Image: []*image.Paletted{palettedFrame(full, 1), palettedFrame(full, 1)},
+			Delay: []int{5, 50},
+		}
+		hashes, err := blurhash.EncodeGIF(3, 3, g)
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if hashes.Representative != hashes.Frames[0] {
+			t.Errorf("representative mismatch: got %q, want %q", hashes.Representative, hashes.Frames[0])
+		}
+	})
+
+	t.Run("time weighted", func(t *testing.T) {
+		g := &gif.GIF{
+			Image: []*image.Paletted{palettedFrame(full, 1), palettedFrame(full, 2)},
+			Delay: []int{10, 90},
+		}
+		hashes, err := blurhash.EncodeGIF(1, 1, g)
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		img, err := blurhash.Decode(hashes.Representative, 1, 1, 1)
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		c := img.(*image.NRGBA).NRGBAAt(0, 0)
+		if c.B <= c.R {
+			t.Errorf("longer blue frame should dominate the representative colour, got %v", c)
+		}
+	})
+
+	t.Run("short delays", func(t *testing.T) {
+		// Delays of 0 and 1 are displayed as 10, and longer ones as
+		// declared.
+		representative := func(delay ...int) string {
+			g := &gif.GIF{
+				Image: []*image.Paletted{palettedFrame(full, 1), palettedFrame(full, 2)},
+				Delay: delay,
+			}
+			hashes, err := blurhash.EncodeGIF(1, 1, g)
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+			return hashes.Representative
+		}
+		for _, test := range [][2][]int{
+			{{0, 1}, {10, 10}},
+			{{1, 30}, {10, 30}},
+			{{2, 8}, {20, 80}},
+		} {
+			if got, want := representative(test[0]...), representative(test[1]...); got != want {
+				t.Errorf("delays %v: representative is %q, want %q as for %v", test[0], got, want, test[1])
+			}
+		}
+		if representative(2, 8) == representative(10, 10) {
+			t.Error("delays of 2 and 8 were weighted equally")
+		}
+	})
+}
+
+func TestEncodeGIFInvalid(t *testing.T) {
+	if _, err := blurhash.EncodeGIF(4, 3, &gif.GIF{}); !errors.Is(err, blurhash.ErrInvalidStream) {
+		t.Errorf("empty gif: expected ErrInvalidStream, got %v", err)
+	}
+
+	g := &gif.GIF{Image: []*image.Paletted{palettedFrame(image.Rect(0, 0, 4, 4), 1)}}
+	if _, err := blurhash.EncodeGIF(10, 3, g); !errors.Is(err, blurhash.ErrInvalidComponents) {
+		t.Errorf("expected ErrInvalidComponents, got %v", err)
+	}
+}
+
+func TestDecodeGIF(t *testing.T) {
+	h := blurhash.GIFHashes{
+		Frames:    []string{testFixtures[0].hash, testFixtures[1].hash},
+		Delay:     []int{20, 40},
+		LoopCount: 3,
+	}
+
+	g, err := blurhash.DecodeGIF(h, 32, 24, 1)
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if len(g.Image) != 2 || g.Delay[0] != 20 || g.Delay[1] != 40 || g.LoopCount != 3 {
+		t.Errorf("unexpected gif metadata: %d frames, delays %v, loop count %d", len(g.Image), g.Delay, g.LoopCount)
+	}
+
+	for i, hash := range h.Frames {
+		want, err := blurhash.Decode(hash, 32, 24, 1)
+		if err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		// Dithering to the frame palette moves pixels only slightly.
+		frame := g.Image[i]
+		var diff, n int
+		for y := 0; y < 24; y++ {
+			for x := 0; x < 32; x++ {
+				got := color.NRGBAModel.Convert(frame.At(x, y)).(color.NRGBA)
+				w := want.(*image.NRGBA).NRGBAAt(x, y)
+				diff += absDiff(got.R, w.R) + absDiff(got.G, w.G) + absDiff(got.B, w.B)
+				n += 3
+			}
+		}
+		if mean := float64(diff) / float64(n); mean > 2 {
+			t.Errorf("frame %d: mean channel error %.2f should be small", i, mean)
+		}
+	}
+
+	if err := gif.EncodeAll(&bytes.Buffer{}, g); err != nil {
+		t.Errorf("error encoding gif: %v", err)
+	}
+
+	if _, err := blurhash.DecodeGIF(blurhash.GIFHashes{}, 32, 24, 1); !errors.Is(err, blurhash.ErrInvalidStream) {
+		t.Errorf("no frames: expected ErrInvalidStream, got %v", err)
+	}
+}
+
+func cloneRGBA(src *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	copy(dst.Pix, src.Pix)
+	return dst
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
@@ -0,0 +1,82 @@
+package blurhash
+
+import "sync"
+
+// rowWorker holds the buffers one goroutine needs to decode rows. The
+// colours and cosine tables of a Decoder are shared read-only between
+// workers.
+type rowWorker struct {
+	// wide is set when the row is written with 16-bit precision.
+	wide bool
+
+	row          []uint8
+	linear       []float64
+	rowColors    [][3]float64
+	diffusion    [2][]float64
+	paletteCache map[uint32]uint8
+	chroma       [][4]int
+	gridRow      []float64
+}
+
+// reset sizes the worker's buffers for rows of the given width,
+// components and dither mode, emptying its palette cache if paletted is
+// set. wide selects 16-bit output.
+func (w *rowWorker) reset(width, numX int, dither Dither, paletted, wide bool) {
+	w.wide = wide
+	w.row = growTo(w.row, width*3)
+	w.linear = growTo(w.linear, width*3)
+	w.rowColors = growTo(w.rowColors, numX)
+	if dither == DitherFloydSteinberg {
+		// One pixel either side of the row catches diffused errors.
+		w.diffusion[0] = growTo(w.diffusion[0], (width+2)*3)
+		w.diffusion[1] = growTo(w.diffusion[1], (width+2)*3)
+	}
+	if !paletted {
+		return
+	}
+	if w.paletteCache == nil {
+		w.paletteCache = make(map[uint32]uint8)
+	}
+	for k := range w.paletteCache {
+		delete(w.paletteCache, k)
+	}
+}
+
+// parallelRows splits the rows [0, height) into contiguous bands and
+// calls fn for each on up to workers goroutines, giving each band its own
+// rowWorker. If boundary is not nil, bands only start at rows for which
+// it returns true. With fewer than two workers, fn is called once on the
+// calling goroutine.
+func (d *Decoder) parallelRows(workers, height int, boundary func(y int) bool, fn func(w *rowWorker, y0, y1 int)) {
+	n := minInt(workers, height)
+	if n < 1 {
+		n = 1
+	}
+	for len(d.workers) < n {
+		d.workers = append(d.workers, rowWorker{})
+	}
+	if n == 1 {
+		fn(&d.workers[0], 0, height)
+		return
+	}
+
+	var wg sync.WaitGroup
+	start := 0
+	for k := 0; k < n && start < height; k++ {
+		end := height
+		if k < n-1 {
+			end = maxInt((k+1)*height/n, start+1)
+			for boundary != nil && end < height && !boundary(end) {
+				end++
+			}
+		}
+
+		wg.Add(1)
+		go func(w *rowWorker, y0, y1 int) {
+			defer wg.Done()
+			fn(w, y0, y1)
+		}(&d.workers[k], start, end)
+		start = end
+	}
+	wg.Wait()
+}
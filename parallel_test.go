@@ -0,0 +1,81 @@
+package blurhash_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestDecodeWorkers(t *testing.T) {
+	hash := testFixtures[2].hash
+	dsts := writerDestinations()
+	dsts["Generic"] = func() draw.Image { return setOnly{image.NewNRGBA(image.Rect(0, 0, 37, 29))} }
+
+	for name, newImage := range dsts {
+		want := newImage()
+		if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		for _, workers := range []int{2, 3, 8, 100} {
+			dec := blurhash.Decoder{Workers: workers}
+			got := newImage()
+			// Decode twice to exercise reused worker buffers.
+			for i := 0; i < 2; i++ {
+				if err := dec.DecodeDraw(got, hash, 1); err != nil {
+					t.Fatalf("error decoding: %v", err)
+				}
+			}
+			b := want.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					if g, w := got.At(x, y), want.At(x, y); g != w {
+						t.Fatalf("%s, workers=%d: pixel (%d, %d) = %v, want %v", name, workers, x, y, g, w)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeYCbCrWorkers(t *testing.T) {
+	hash := testFixtures[2].hash
+	ratios := []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio410,
+	}
+	for _, ratio := range ratios {
+		r := image.Rect(1, -3, 30, 22)
+		want := image.NewYCbCr(r, ratio)
+		if err := blurhash.DecodeYCbCr(want, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		for _, workers := range []int{2, 5, 100} {
+			dec := blurhash.Decoder{Workers: workers}
+			got := image.NewYCbCr(r, ratio)
+			if err := dec.DecodeYCbCr(got, hash, 1); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+			if !bytes.Equal(got.Y, want.Y) || !bytes.Equal(got.Cb, want.Cb) || !bytes.Equal(got.Cr, want.Cr) {
+				t.Errorf("%v, workers=%d: output differs from one worker", ratio, workers)
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeWorkers(b *testing.B) {
+	hash := testFixtures[2].hash
+	dst := image.NewRGBA(image.Rect(0, 0, 1280, 720))
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprint(workers), func(b *testing.B) {
+			dec := blurhash.Decoder{Workers: workers}
+			for i := 0; i < b.N; i++ {
+				_ = dec.DecodeDraw(dst, hash, 1)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Placeholder is an image.Image that evaluates a blurhash on demand
+// rather than holding pixels. It can be passed to draw.Draw, scalers or
+// compositors at any declared size for the cost of its cosine tables.
+//
+// Each pixel matches the one DecodeDraw writes for the same hash, size and
+// Decoder options. Dither, HighBitDepth and Approximation do not apply.
+//
+// A Placeholder is immutable and safe for concurrent use.
+type Placeholder struct {
+	width, height int
+	numX, numY    int
+	profile       Profile
+	colors        [][3]float64
+	cosX, cosY    []float64
+	fitX, fitY    fitAxis
+	border        bool // pixels beyond the hash take its DC colour
+}
+
+// Placeholder returns a Placeholder of the given size for the hash,
+// evaluated with the decoder's Profile, Deterministic, Detail and Fit
+// options.
+func (d *Decoder) Placeholder(hash string, width, height int, punch float64) (*Placeholder, error) {
+	if err := d.Profile.check(); err != nil {
+		return nil, err
+	}
+	if err := d.Detail.check(); err != nil {
+		return nil, err
+	}
+	if err := d.Fit.check(); err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("%w: had width=%d, height=%d", ErrInvalidDimensions, width, height)
+	}
+	numX, numY, err := Components(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	colors := make([][3]float64, numX*numY)
+	if err := decodeColors(colors, hash, punch, d.Profile); err != nil {
+		return nil, err
+	}
+	numX, numY = d.Detail.apply(colors, numX, numY)
+	fitX, fitY := d.Fit.axes(width, height)
+
+	p := &Placeholder{
+		width:   width,
+		height:  height,
+		numX:    numX,
+		numY:    numY,
+		profile: d.Profile,
+		colors:  colors[:numX*numY],
+		cosX:    make([]float64, numX*width),
+		cosY:    make([]float64, numY*height),
+		fitX:    fitX,
+		fitY:    fitY,
+		border:  d.Fit.dcBorder(fitX, fitY),
+	}
+	d.fillAxisCosines(p.cosX, numX, fitX)
+	d.fillAxisCosines(p.cosY, numY, fitY)
+	return p, nil
+}
+
+// ColorModel returns color.NRGBAModel.
+func (p *Placeholder) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+
+// Bounds returns the declared size of the placeholder, with its origin at
+// (0, 0).
+func (p *Placeholder) Bounds() image.Rectangle {
+	return image.Rect(0, 0, p.width, p.height)
+}
+
+// Opaque reports that every pixel of the placeholder is opaque.
+func (p *Placeholder) Opaque() bool {
+	return true
+}
+
+// At returns the colour of the pixel at (x, y), or transparent black
+// outside the bounds.
+func (p *Placeholder) At(x, y int) color.Color {
+	return p.NRGBAAt(x, y)
+}
+
+// NRGBAAt returns the colour of the pixel at (x, y), or transparent black
+// outside the bounds.
+func (p *Placeholder) NRGBAAt(x, y int) color.NRGBA {
+	if x < 0 || y < 0 || x >= p.width || y >= p.height {
+		return color.NRGBA{}
+	}
+	r, g, b := p.linear(x, y)
+	return color.NRGBA{
+		R: uint8(p.profile.linearToSRGB(r)),
+		G: uint8(p.profile.linearToSRGB(g)),
+		B: uint8(p.profile.linearToSRGB(b)),
+		A: 255,
+	}
+}
+
+// RGBA64At returns the colour of the pixel at (x, y), or transparent
+// black outside the bounds. It saves the allocation of At in draw.Draw.
+func (p *Placeholder) RGBA64At(x, y int) color.RGBA64 {
+	c := p.NRGBAAt(x, y)
+	return color.RGBA64{
+		R: uint16(c.R) * 0x101,
+		G: uint16(c.G) * 0x101,
+		B: uint16(c.B) * 0x101,
+		A: uint16(c.A) * 0x101,
+	}
+}
+
+// linear evaluates the pixel at (x, y) in linear RGB with the same
+// arithmetic as decodeRow.
+func (p *Placeholder) linear(x, y int) (r, g, b float64) {
+	if p.border && !(p.fitX.shows(x) && p.fitY.shows(y)) {
+		// As fillBorder.
+		dc := p.colors[0]
+		return dc[0], dc[1], dc[2]
+	}
+	if p.profile == ProfileReference {
+		// As referenceDecodeRow, one basis function at a time.
+		for j := 0; j < p.numY; j++ {
+			basisY := p.cosY[j*p.height+y]
+			for i := 0; i < p.numX; i++ {
+				basis := p.cosX[i*p.width+x] * basisY
+				c := p.colors[i+j*p.numX]
+				r += float64(c[0] * basis)
+				g += float64(c[1] * basis)
+				b += float64(c[2] * basis)
+			}
+		}
+		return r, g, b
+	}
+
+	for i := 0; i < p.numX; i++ {
+		// Collapse the y bases of column i, as decodeRow does for a row.
+		var cr, cg, cb float64
+		for j := 0; j < p.numY; j++ {
+			basisY := p.cosY[j*p.height+y]
+			c := p.colors[i+j*p.numX]
+			// Explicit conversions prevent fused multiply-adds.
+			cr += float64(c[0] * basisY)
+			cg += float64(c[1] * basisY)
+			cb += float64(c[2] * basisY)
+		}
+		basisX := p.cosX[i*p.width+x]
+		r += float64(cr * basisX)
+		g += float64(cg * basisX)
+		b += float64(cb * basisX)
+	}
+	return r, g, b
+}
+
+// NewPlaceholder returns a Placeholder of the given size for the hash.
+func NewPlaceholder(hash string, width, height int, punch float64) (*Placeholder, error) {
+	var d Decoder
+	return d.Placeholder(hash, width, height, punch)
+}
@@ -0,0 +1,134 @@
+package blurhash_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestPlaceholder(t *testing.T) {
+	decoders := []blurhash.Decoder{
+		{},
+		{Deterministic: true},
+		{Profile: blurhash.ProfileLegacy},
+		{Profile: blurhash.ProfileReference},
+	}
+	for _, test := range testFixtures {
+		if test.hash == "" {
+			continue
+		}
+		for _, dec := range decoders {
+			const width, height = 37, 23
+			want := image.NewNRGBA(image.Rect(0, 0, width, height))
+			if err := dec.DecodeDraw(want, test.hash, 1.5); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+			p, err := dec.Placeholder(test.hash, width, height, 1.5)
+			if err != nil {
+				t.Fatalf("error creating placeholder: %v", err)
+			}
+
+			if p.Bounds() != want.Bounds() {
+				t.Fatalf("bounds are %v, want %v", p.Bounds(), want.Bounds())
+			}
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					if got, w := p.At(x, y), want.NRGBAAt(x, y); got != w {
+						t.Fatalf("%s %v: pixel (%d, %d) = %v, want %v", test.hash, dec.Profile, x, y, got, w)
+					}
+				}
+			}
+
+			// Drawing the placeholder renders the same pixels.
+			drawn := image.NewRGBA(p.Bounds())
+			draw.Draw(drawn, drawn.Bounds(), p, image.Point{}, draw.Src)
+			for y := 0; y < height; y++ {
+				for x := 0; x < width; x++ {
+					if got, w := drawn.RGBAAt(x, y), color.RGBAModel.Convert(want.At(x, y)); got != w {
+						t.Fatalf("%s %v: drawn pixel (%d, %d) = %v, want %v", test.hash, dec.Profile, x, y, got, w)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPlaceholderOutside(t *testing.T) {
+	p, err := blurhash.NewPlaceholder(testFixtures[0].hash, 8, 8, 1)
+	if err != nil {
+		t.Fatalf("error creating placeholder: %v", err)
+	}
+	for _, pt := range []image.Point{{-1, 0}, {0, -1}, {8, 0}, {0, 8}} {
+		if c := p.At(pt.X, pt.Y); c != (color.NRGBA{}) {
+			t.Errorf("pixel %v outside the bounds is %v, want transparent", pt, c)
+		}
+		if c := p.RGBA64At(pt.X, pt.Y); c != (color.RGBA64{}) {
+			t.Errorf("pixel %v outside the bounds is %v, want transparent", pt, c)
+		}
+	}
+}
+
+func TestPlaceholderConcurrent(t *testing.T) {
+	const size = 64
+	hash := testFixtures[2].hash
+	want := image.NewNRGBA(image.Rect(0, 0, size, size))
+	if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	p, err := blurhash.NewPlaceholder(hash, size, size, 1)
+	if err != nil {
+		t.Fatalf("error creating placeholder: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			// Each goroutine scans the image from a different row.
+			for n := 0; n < size; n++ {
+				y := (n + g*size/8) % size
+				for x := 0; x < size; x++ {
+					if p.NRGBAAt(x, y) != want.NRGBAAt(x, y) {
+						errs <- "concurrent At returned a different pixel"
+						return
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPlaceholderInvalid(t *testing.T) {
+	hash := testFixtures[0].hash
+	if _, err := blurhash.NewPlaceholder(hash, 0, 8, 1); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidDimensions, err)
+	}
+	if _, err := blurhash.NewPlaceholder(hash[:len(hash)-1], 8, 8, 1); !errors.Is(err, blurhash.ErrInvalidHash) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidHash, err)
+	}
+	dec := blurhash.Decoder{Profile: -1}
+	if _, err := dec.Placeholder(hash, 8, 8, 1); !errors.Is(err, blurhash.ErrInvalidProfile) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidProfile, err)
+	}
+}
+
+func BenchmarkPlaceholderDraw(b *testing.B) {
+	hash := testFixtures[0].hash
+	dst := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for i := 0; i < b.N; i++ {
+		p, _ := blurhash.NewPlaceholder(hash, 32, 32, 1)
+		draw.Draw(dst, dst.Bounds(), p, image.Point{}, draw.Src)
+	}
+}
@@ -0,0 +1,163 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"sync"
+)
+
+// PlanOptions selects the arithmetic of a Plan. The zero value matches
+// zero-value Encoders and Decoders.
+type PlanOptions struct {
+	// Deterministic computes the cosine tables as Encoder.Deterministic
+	// and Decoder.Deterministic do.
+	Deterministic bool
+
+	// Profile selects the version of the encoding and decoding
+	// arithmetic. The zero value is ProfileV12.
+	Profile Profile
+}
+
+// Plan holds the cosine tables for encoding and decoding images of one
+// size with one number of components, so that a server working at a
+// canonical size computes them once rather than on every call.
+//
+// Hashes and pixels are identical to those of an Encoder and Decoder with
+// the same options. Encoder.Optimise and the Decoder options other than
+// Deterministic and Profile do not apply.
+//
+// A Plan is immutable and safe for concurrent use. Each call borrows
+// small scratch buffers from a pool, so its allocations are amortised as
+// those of a reused Encoder or Decoder are.
+type Plan struct {
+	width, height            int
+	xComponents, yComponents int
+	profile                  Profile
+
+	// The tables are only read once the plan is built. The encoder's
+	// tables are the decoder's except under ProfileReference.
+	cosX, cosY             []float64
+	encodeCosX, encodeCosY []float64
+
+	// Pools of scratch Encoders and Decoders that share the tables above.
+	// Their cosine buffers must never be refilled.
+	encoders, decoders sync.Pool
+}
+
+// NewPlan precomputes a Plan for images of the given size and hashes of
+// the given components.
+func NewPlan(width, height, xComponents, yComponents int, opts PlanOptions) (*Plan, error) {
+	if err := opts.Profile.check(); err != nil {
+		return nil, err
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("%w: had width=%d, height=%d", ErrInvalidDimensions, width, height)
+	}
+	if err := checkComponents(xComponents, yComponents); err != nil {
+		return nil, err
+	}
+
+	p := &Plan{
+		width:       width,
+		height:      height,
+		xComponents: xComponents,
+		yComponents: yComponents,
+		profile:     opts.Profile,
+		cosX:        make([]float64, xComponents*width),
+		cosY:        make([]float64, yComponents*height),
+	}
+	if opts.Profile == ProfileReference {
+		fillReferenceCosines(p.cosX, xComponents, width, true)
+		fillReferenceCosines(p.cosY, yComponents, height, true)
+		p.encodeCosX = make([]float64, xComponents*width)
+		p.encodeCosY = make([]float64, yComponents*height)
+		fillReferenceCosines(p.encodeCosX, xComponents, width, false)
+		fillReferenceCosines(p.encodeCosY, yComponents, height, false)
+	} else {
+		fillCosines(p.cosX, xComponents, width, opts.Deterministic)
+		fillCosines(p.cosY, yComponents, height, opts.Deterministic)
+		p.encodeCosX, p.encodeCosY = p.cosX, p.cosY
+	}
+
+	p.encoders.New = func() any {
+		return &Encoder{Profile: p.profile, cosX: p.encodeCosX, cosY: p.encodeCosY}
+	}
+	p.decoders.New = func() any {
+		return &Decoder{Profile: p.profile, cosX: p.cosX, cosY: p.cosY}
+	}
+	return p, nil
+}
+
+// Size returns the width and height of the images of the plan.
+func (p *Plan) Size() (width, height int) {
+	return p.width, p.height
+}
+
+// Components returns the number of components of the plan.
+func (p *Plan) Components() (x, y int) {
+	return p.xComponents, p.yComponents
+}
+
+// Encode returns the blurhash of img, which must have the size of the
+// plan, with the components of the plan.
+func (p *Plan) Encode(img image.Image) (string, error) {
+	bounds := img.Bounds()
+	if err := p.checkSize(bounds.Dx(), bounds.Dy()); err != nil {
+		return "", err
+	}
+
+	e := p.encoders.Get().(*Encoder)
+	defer p.encoders.Put(e)
+	pix, stride := e.packedPixels(img)
+	e.factors = growTo(e.factors, p.xComponents*p.yComponents)
+	e.multiplyFactors(p.xComponents, p.yComponents, pix, stride, layoutRGBA, p.width, p.height)
+	return e.writeHash(p.xComponents, p.yComponents, e.factors)
+}
+
+// Decode decodes a blurhash to a new NRGBA image of the size of the plan.
+// The hash may have fewer components than the plan, but not more.
+func (p *Plan) Decode(hash string, punch int) (image.Image, error) {
+	img := image.NewNRGBA(image.Rect(0, 0, p.width, p.height))
+	if err := p.DecodeDraw(img, hash, float64(punch)); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
+
+// DecodeDraw decodes a blurhash into dst, which must have the size of the
+// plan, as Decoder.DecodeDraw does. The hash may have fewer components
+// than the plan, but not more.
+func (p *Plan) DecodeDraw(dst draw.Image, hash string, punch float64) error {
+	bounds := dst.Bounds()
+	if err := p.checkSize(bounds.Dx(), bounds.Dy()); err != nil {
+		return err
+	}
+	numX, numY, err := Components(hash)
+	if err != nil {
+		return err
+	}
+	if numX > p.xComponents || numY > p.yComponents {
+		return fmt.Errorf("%w: plan has x=%d, y=%d, hash had x=%d, y=%d",
+			ErrInvalidComponents, p.xComponents, p.yComponents, numX, numY)
+	}
+
+	// The tables of fewer components are a prefix of the plan's.
+	d := p.decoders.Get().(*Decoder)
+	defer p.decoders.Put(d)
+	d.colors = growTo(d.colors, numX*numY)
+	if err := decodeColors(d.colors, hash, punch, p.profile); err != nil {
+		return err
+	}
+	d.drawRows(dst, numX, numY)
+	return nil
+}
+
+// checkSize returns an error if an image of the given size does not have
+// the size of the plan.
+func (p *Plan) checkSize(width, height int) error {
+	if width != p.width || height != p.height {
+		return fmt.Errorf("%w: plan is %dx%d, had %dx%d", ErrInvalidDimensions, p.width, p.height, width, height)
+	}
+	return nil
+}
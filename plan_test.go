@@ -0,0 +1,178 @@
+package blurhash_test
+
+import (
+	"errors"
+	"image"
+	"image/draw"
+	"sync"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestPlan(t *testing.T) {
+	options := []blurhash.PlanOptions{
+		{},
+		{Deterministic: true},
+		{Profile: blurhash.ProfileLegacy},
+		{Profile: blurhash.ProfileReference},
+	}
+	for _, test := range testFixtures {
+		if test.file == "" || test.hash == "" {
+			continue
+		}
+		src := loadNRGBA(t, test.file)
+		bounds := src.Bounds()
+		// A 16-bit copy takes the conversion path of Encode.
+		wide := image.NewRGBA64(bounds)
+		draw.Draw(wide, bounds, src, bounds.Min, draw.Src)
+
+		for _, opts := range options {
+			plan, err := blurhash.NewPlan(bounds.Dx(), bounds.Dy(), test.xComp, test.yComp, opts)
+			if err != nil {
+				t.Fatalf("error creating plan: %v", err)
+			}
+			enc := blurhash.Encoder{Deterministic: opts.Deterministic, Profile: opts.Profile}
+			for _, img := range []image.Image{src, wide} {
+				want, err := enc.Encode(test.xComp, test.yComp, img)
+				if err != nil {
+					t.Fatalf("error encoding: %v", err)
+				}
+				got, err := plan.Encode(img)
+				if err != nil {
+					t.Fatalf("error encoding with plan: %v", err)
+				}
+				if got != want {
+					t.Errorf("%s %v: plan encoded %q, want %q", test.file, opts.Profile, got, want)
+				}
+			}
+
+			// The plan decodes its own hash and any with fewer components.
+			dec := blurhash.Decoder{Deterministic: opts.Deterministic, Profile: opts.Profile}
+			hashes := []string{test.hash}
+			if small, err := enc.Encode(1, 2, src); err == nil {
+				hashes = append(hashes, small)
+			}
+			for _, hash := range hashes {
+				want := image.NewRGBA(bounds)
+				if err := dec.DecodeDraw(want, hash, 1.5); err != nil {
+					t.Fatalf("error decoding: %v", err)
+				}
+				got := image.NewRGBA(bounds)
+				if err := plan.DecodeDraw(got, hash, 1.5); err != nil {
+					t.Fatalf("error decoding with plan: %v", err)
+				}
+				for i := range want.Pix {
+					if got.Pix[i] != want.Pix[i] {
+						t.Fatalf("%s %v: plan decoded byte %d as %d, want %d", hash, opts.Profile, i, got.Pix[i], want.Pix[i])
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestPlanConcurrent(t *testing.T) {
+	test := testFixtures[0]
+	src := loadNRGBA(t, test.file)
+	w, h := src.Bounds().Dx(), src.Bounds().Dy()
+	plan, err := blurhash.NewPlan(w, h, test.xComp, test.yComp, blurhash.PlanOptions{})
+	if err != nil {
+		t.Fatalf("error creating plan: %v", err)
+	}
+	want, err := blurhash.Decode(test.hash, w, h, 1)
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 4; n++ {
+				hash, err := plan.Encode(src)
+				if err != nil || hash != test.hash {
+					errs <- "concurrent Encode returned a different hash"
+					return
+				}
+				img, err := plan.Decode(hash, 1)
+				if err != nil {
+					errs <- err.Error()
+					return
+				}
+				got, exp := img.(*image.NRGBA).Pix, want.(*image.NRGBA).Pix
+				for i := range exp {
+					if got[i] != exp[i] {
+						errs <- "concurrent Decode returned different pixels"
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestPlanInvalid(t *testing.T) {
+	if _, err := blurhash.NewPlan(0, 8, 4, 3, blurhash.PlanOptions{}); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidDimensions, err)
+	}
+	if _, err := blurhash.NewPlan(8, 8, 10, 3, blurhash.PlanOptions{}); !errors.Is(err, blurhash.ErrInvalidComponents) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidComponents, err)
+	}
+	if _, err := blurhash.NewPlan(8, 8, 4, 3, blurhash.PlanOptions{Profile: -1}); !errors.Is(err, blurhash.ErrInvalidProfile) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidProfile, err)
+	}
+
+	plan, err := blurhash.NewPlan(8, 8, 3, 3, blurhash.PlanOptions{})
+	if err != nil {
+		t.Fatalf("error creating plan: %v", err)
+	}
+	if _, err := plan.Encode(image.NewNRGBA(image.Rect(0, 0, 8, 9))); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidDimensions, err)
+	}
+	if err := plan.DecodeDraw(image.NewNRGBA(image.Rect(0, 0, 9, 8)), testFixtures[0].hash, 1); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidDimensions, err)
+	}
+	// The fixture has 4x3 components, more than the plan.
+	if _, err := plan.Decode(testFixtures[0].hash, 1); !errors.Is(err, blurhash.ErrInvalidComponents) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidComponents, err)
+	}
+	if _, err := plan.Decode("LFE.@D9F01_2%L%MIVD*9Goe-;W", 1); !errors.Is(err, blurhash.ErrInvalidHash) {
+		t.Errorf("expected %v, got %v", blurhash.ErrInvalidHash, err)
+	}
+}
+
+func BenchmarkPlanDecodeDraw(b *testing.B) {
+	for _, test := range testFixtures {
+		if test.hash == "" {
+			continue
+		}
+
+		b.Run(test.hash, func(b *testing.B) {
+			plan, _ := blurhash.NewPlan(32, 32, test.xComp, test.yComp, blurhash.PlanOptions{})
+			dst := image.NewRGBA(image.Rect(0, 0, 32, 32))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = plan.DecodeDraw(dst, test.hash, 1)
+			}
+		})
+	}
+}
+
+func BenchmarkPlanEncode(b *testing.B) {
+	test := testFixtures[0]
+	img := blurhash.LoadFixture(b, test.file).(*image.NRGBA)
+	bounds := img.Bounds()
+	plan, _ := blurhash.NewPlan(bounds.Dx(), bounds.Dy(), test.xComp, test.yComp, blurhash.PlanOptions{})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = plan.Encode(img)
+	}
+}
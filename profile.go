@@ -0,0 +1,110 @@
+package blurhash
+
+import (
+	"fmt"
+	"math"
+)
+
+// Profile selects a version of the arithmetic used to encode and decode
+// hashes. Each profile's output is frozen by golden tests, so hashes and
+// pixels produced under a profile never change between releases. Changes
+// to the arithmetic are introduced as new profiles rather than by
+// altering existing ones.
+//
+// Profiles only differ in rounding edge cases and the quirks of the
+// implementations they reproduce; every profile produces standard hashes
+// that decode with any blurhash decoder.
+type Profile int
+
+const (
+	// ProfileV12 is the arithmetic introduced in v1.2, which converts
+	// linear values to sRGB using a 4096-entry lookup table.
+	ProfileV12 Profile = iota
+
+	// ProfileLegacy is the arithmetic of v1.1.1, the last release before
+	// v1.2, which evaluates the sRGB transfer function for every value,
+	// including its quirk of darkening values near black. Its output is
+	// checked against goldens produced by that release. It sums in a
+	// different order, so a value falling within rounding error of a
+	// step may still differ from that release by one step.
+	ProfileLegacy
+
+	// ProfileReference reproduces the arithmetic of the TypeScript
+	// reference implementation (github.com/woltapp/blurhash) exactly,
+	// so hashes and pixels match those of clients using it. This
+	// includes its quirks: the encoder quantises the maximum AC value
+	// from signed rather than absolute values, clipping large negative
+	// coefficients. Its decoder coerces punch with punch|1, so callers
+	// matching it should pass the coerced value. Reference arithmetic
+	// is bit-identical on every GOARCH regardless of Deterministic.
+	//
+	// The C and Swift implementations compute in single precision with
+	// the platform maths library, and so can't be reproduced exactly;
+	// their output is within one sRGB step of this profile.
+	ProfileReference
+)
+
+// String returns the name of p.
+func (p Profile) String() string {
+	switch p {
+	case ProfileV12:
+		return "v1.2"
+	case ProfileLegacy:
+		return "legacy"
+	case ProfileReference:
+		return "reference"
+	default:
+		return fmt.Sprintf("Profile(%d)", int(p))
+	}
+}
+
+// check returns an error if p is not a known profile.
+func (p Profile) check() error {
+	if p < ProfileV12 || p > ProfileReference {
+		return fmt.Errorf("%w: %v", ErrInvalidProfile, p)
+	}
+	return nil
+}
+
+// linearToSRGB converts a linear value to an 8-bit sRGB value using the
+// arithmetic of p.
+func (p Profile) linearToSRGB(val float64) int {
+	switch p {
+	case ProfileLegacy:
+		return exactLinearToSRGB(val)
+	case ProfileReference:
+		return referenceLinearToSRGB(val)
+	default:
+		return linearToSRGB(val)
+	}
+}
+
+// sRGBToLinear converts an 8-bit sRGB value to linear using the
+// arithmetic of p.
+func (p Profile) sRGBToLinear(val int) float64 {
+	if p == ProfileReference {
+		return referenceSRGBToLinear(val)
+	}
+	return sRGBToLinear(val)
+}
+
+// magnitude returns the value of an AC coefficient that p compares when
+// finding the maximum AC value.
+func (p Profile) magnitude(v float64) float64 {
+	if p == ProfileReference {
+		return v
+	}
+	return math.Abs(v)
+}
+
+// exactLinearToSRGB evaluates the sRGB transfer function directly, as
+// linearToSRGB did in v1.1.1 before it was replaced by a lookup table.
+// That includes its quirk of halving, rather than rounding, values in the
+// linear segment near black.
+func exactLinearToSRGB(val float64) int {
+	v := math.Max(0, math.Min(1, val))
+	if v <= 0.0031308 {
+		return int(float64(v*12.92*255) * 0.5)
+	}
+	return int(float64(float64(1.055*math.Pow(v, 1/2.4)-0.055)*255) + 0.5)
+}
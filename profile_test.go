@@ -0,0 +1,116 @@
+package blurhash_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+// profileHashes freeze the hashes produced under each profile. An empty
+// file refers to stripesImage, whose mean colour falls on a rounding edge
+// case that distinguishes the profiles.
+//
+// Goldens are produced with Deterministic set so they hold on every GOARCH.
+// The ProfileLegacy goldens here and in profilePixels were produced by the
+// Encode and DecodeDraw functions of the v1.1.1 release.
+var profileHashes = []struct {
+	profile      blurhash.Profile
+	file         string
+	xComp, yComp int
+	hash         string
+}{
+	{blurhash.ProfileV12, "fixtures/test.png", 4, 3, "LFE.@D9F01_2%L%MIVD*9Goe-;WB"},
+	{blurhash.ProfileV12, "fixtures/octocat.png", 9, 9, "|NAdApj[00ayxGayx]j[R*mkj[TKayt7fQaKj[WB9}ay-Sj[NHj[Wqayt74:fk?HjtNGf6Rkays;s8j[R-f6t7ayadfQWB_3f69Zfkn$j[xuj[WVVXj[g4ayozj@e-j[WBXoj[nNf6V@aybIaykCMwaytSj[oefQWBayWC"},
+	{blurhash.ProfileV12, "fixtures/dalle.png", 4, 3, "LaF#5R0#WBjY58-nWCWBbIsTbbay"},
+	{blurhash.ProfileV12, "", 1, 1, "000vPA"},
+	{blurhash.ProfileV12, "", 4, 3, "L00vPAfQfQfQt7fQfQfQfQfQfQfQ"},
+	{blurhash.ProfileLegacy, "fixtures/test.png", 4, 3, "LFE.@D9F01_2%L%MIVD*9Goe-;WB"},
+	{blurhash.ProfileLegacy, "fixtures/octocat.png", 9, 9, "|NAdApj[00ayxGayx]j[R*mkj[TKayt7fQaKj[WB9}ay-Sj[NHj[Wqayt74:fk?HjtNGf6Rkays;s8j[R-f6t7ayadfQWB_3f69Zfkn$j[xuj[WVVXj[g4ayozj@e-j[WBXoj[nNf6V@aybIaykCMwaytSj[oefQWBayWC"},
+	{blurhash.ProfileLegacy, "fixtures/dalle.png", 4, 3, "LaF#5R0#WBjY58-nWCWBbIsTbbay"},
+	{blurhash.ProfileLegacy, "", 1, 1, "000Ss5"},
+	{blurhash.ProfileLegacy, "", 4, 3, "L00Ss5fQfQfQt7fQfQfQfQfQfQfQ"},
+}
+
+// profilePixels freeze SHA-256 digests of the 32x32 NRGBA pixels decoded
+// under each profile with a punch of 1.
+var profilePixels = []struct {
+	profile blurhash.Profile
+	hash    string
+	digest  string
+}{
+	{blurhash.ProfileV12, "LFE.@D9F01_2%L%MIVD*9Goe-;WB", "d747339f80e96e80e1b5ce217355a51eb476e2a23a5b68bbf5133910eaf433e2"},
+	{blurhash.ProfileV12, "LNAdApj[00aymkj[TKay9}ay-Sj[", "9651caed98c0d631cc53a9718f779db494fe5d8ec6853cda6e66bb83f3e86fc6"},
+	{blurhash.ProfileV12, "eaF#5R0#WBjYR+58-nWCWBn~bIsTbbayjFWof8jFj[WX-nNHR*jss.", "a28be0f4b156e7580fba961d7c3bfe077e29b0684d94a70cfbbf525f13ad0ae3"},
+	{blurhash.ProfileV12, "LNMF%n00%#MwS|WCWEM{R*bbWBbH", "3c1a35b8b860059b2a294d2f5f4334d7ce3c4594a23d0f8a2584223d9a3e2d4e"},
+	{blurhash.ProfileV12, "KJG8_@Dgx]_4V?xuyE%NRj", "43acba3c200126b2cc0b575ca2c2edd322911feacdb3e38b571e484fc26a4a04"},
+	{blurhash.ProfileLegacy, "LFE.@D9F01_2%L%MIVD*9Goe-;WB", "51472a74f1a4cd156c0f015b2d24205a9d1d7b4f713654f1adffafc3838be263"},
+	{blurhash.ProfileLegacy, "LNAdApj[00aymkj[TKay9}ay-Sj[", "a1bc19c68bfa1048f2034fa9f319ad6f262f32b4edcb80bb242f13a6bbe98d96"},
+	{blurhash.ProfileLegacy, "eaF#5R0#WBjYR+58-nWCWBn~bIsTbbayjFWof8jFj[WX-nNHR*jss.", "226448cc4592cc469b513824bb7e80f0bf5e9590e65d0e4e5298387b0aec6cc4"},
+	{blurhash.ProfileLegacy, "LNMF%n00%#MwS|WCWEM{R*bbWBbH", "e39b808dd3cf85ce9df53bdb9ad800b4fae20f66a4799f8777c3ad70da1cbc12"},
+	{blurhash.ProfileLegacy, "KJG8_@Dgx]_4V?xuyE%NRj", "83cb4b628ed8296e766571b728a567ec39dcd8806dc389aa7311dd4e95e9acba"},
+}
+
+func TestProfileEncode(t *testing.T) {
+	images := map[string]image.Image{"": stripesImage()}
+	for _, test := range profileHashes {
+		img, ok := images[test.file]
+		if !ok {
+			img = blurhash.LoadFixture(t, test.file)
+			images[test.file] = img
+		}
+
+		enc := blurhash.Encoder{Profile: test.profile, Deterministic: true}
+		hash, err := enc.Encode(test.xComp, test.yComp, img)
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if hash != test.hash {
+			t.Errorf("%v %q %dx%d: hash mismatch: got %q, want %q", test.profile, test.file, test.xComp, test.yComp, hash, test.hash)
+		}
+	}
+}
+
+func TestProfileDecode(t *testing.T) {
+	for _, test := range profilePixels {
+		dec := blurhash.Decoder{Profile: test.profile, Deterministic: true}
+		dst := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		if err := dec.DecodeDraw(dst, test.hash, 1); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		sum := sha256.Sum256(dst.Pix)
+		if got := hex.EncodeToString(sum[:]); got != test.digest {
+			t.Errorf("%v %s: pixel digest mismatch: got %s, want %s", test.profile, test.hash, got, test.digest)
+		}
+	}
+}
+
+func TestProfileInvalid(t *testing.T) {
+	enc := blurhash.Encoder{Profile: -1}
+	if _, err := enc.Encode(4, 3, stripesImage()); !errors.Is(err, blurhash.ErrInvalidProfile) {
+		t.Errorf("encode: expected ErrInvalidProfile, got %v", err)
+	}
+	dec := blurhash.Decoder{Profile: 100}
+	if err := dec.DecodeDraw(image.NewNRGBA(image.Rect(0, 0, 4, 4)), testFixtures[0].hash, 1); !errors.Is(err, blurhash.ErrInvalidProfile) {
+		t.Errorf("decode: expected ErrInvalidProfile, got %v", err)
+	}
+}
+
+// stripesImage returns an image of alternating black and dark grey
+// columns.
+func stripesImage() image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 1; x < 8; x += 2 {
+			img.SetNRGBA(x, y, color.NRGBA{13, 13, 13, 255})
+		}
+		for x := 0; x < 8; x += 2 {
+			img.SetNRGBA(x, y, color.NRGBA{0, 0, 0, 255})
+		}
+	}
+	return img
+}
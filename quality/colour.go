@@ -0,0 +1,132 @@
+package quality
+
+import "math"
+
+// srgbToLinear maps 8-bit sRGB values to linear light.
+var srgbToLinear [256]float64
+
+func init() {
+	for i := range srgbToLinear {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			srgbToLinear[i] = v / 12.92
+		} else {
+			srgbToLinear[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+}
+
+// linearToSRGB converts a linear value to sRGB in the range [0, 255],
+// without rounding.
+func linearToSRGB(v float64) float64 {
+	v = math.Max(0, math.Min(1, v))
+	if v <= 0.0031308 {
+		return v * 12.92 * 255
+	}
+	return (1.055*math.Pow(v, 1/2.4) - 0.055) * 255
+}
+
+// lab is a colour in CIELAB, relative to the D65 white point.
+type lab struct {
+	L, A, B float64
+}
+
+// linearToLab converts a linear sRGB colour to CIELAB.
+func linearToLab(c [3]float64) lab {
+	x := (0.4124564*c[0] + 0.3575761*c[1] + 0.1804375*c[2]) / 0.95047
+	y := 0.2126729*c[0] + 0.7151522*c[1] + 0.0721750*c[2]
+	z := (0.0193339*c[0] + 0.1191920*c[1] + 0.9503041*c[2]) / 1.08883
+
+	fx, fy, fz := labF(x), labF(y), labF(z)
+	return lab{
+		L: 116*fy - 16,
+		A: 500 * (fx - fy),
+		B: 200 * (fy - fz),
+	}
+}
+
+func labF(t float64) float64 {
+	const epsilon, kappa = 216.0 / 24389, 24389.0 / 27
+	if t > epsilon {
+		return math.Cbrt(t)
+	}
+	return (kappa*t + 16) / 116
+}
+
+// deltaE2000 returns the CIEDE2000 colour difference between two colours.
+// See Sharma, Wu and Dalal, "The CIEDE2000 Color-Difference Formula:
+// Implementation Notes, Supplementary Test Data, and Mathematical
+// Observations" (2005).
+func deltaE2000(c1, c2 lab) float64 {
+	const pow25to7 = 6103515625 // 25^7
+
+	cBar := (math.Hypot(c1.A, c1.B) + math.Hypot(c2.A, c2.B)) / 2
+	cBar7 := math.Pow(cBar, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25to7)))
+
+	a1, a2 := (1+g)*c1.A, (1+g)*c2.A
+	cp1, cp2 := math.Hypot(a1, c1.B), math.Hypot(a2, c2.B)
+	hp1, hp2 := hueAngle(c1.B, a1), hueAngle(c2.B, a2)
+
+	dL := c2.L - c1.L
+	dC := cp2 - cp1
+
+	var dh float64
+	if cp1*cp2 != 0 {
+		dh = hp2 - hp1
+		if dh > 180 {
+			dh -= 360
+		} else if dh < -180 {
+			dh += 360
+		}
+	}
+	dH := 2 * math.Sqrt(cp1*cp2) * math.Sin(radians(dh/2))
+
+	lBar := (c1.L + c2.L) / 2
+	cpBar := (cp1 + cp2) / 2
+
+	hBar := hp1 + hp2
+	if cp1*cp2 != 0 {
+		switch {
+		case math.Abs(hp1-hp2) <= 180:
+			hBar /= 2
+		case hp1+hp2 < 360:
+			hBar = (hBar + 360) / 2
+		default:
+			hBar = (hBar - 360) / 2
+		}
+	}
+
+	t := 1 - 0.17*math.Cos(radians(hBar-30)) +
+		0.24*math.Cos(radians(2*hBar)) +
+		0.32*math.Cos(radians(3*hBar+6)) -
+		0.20*math.Cos(radians(4*hBar-63))
+
+	dTheta := 30 * math.Exp(-math.Pow((hBar-275)/25, 2))
+	cpBar7 := math.Pow(cpBar, 7)
+	rC := 2 * math.Sqrt(cpBar7/(cpBar7+pow25to7))
+	lBar50 := (lBar - 50) * (lBar - 50)
+	sL := 1 + 0.015*lBar50/math.Sqrt(20+lBar50)
+	sC := 1 + 0.045*cpBar
+	sH := 1 + 0.015*cpBar*t
+	rT := -math.Sin(radians(2*dTheta)) * rC
+
+	l, c, h := dL/sL, dC/sC, dH/sH
+	return math.Sqrt(l*l + c*c + h*h + rT*c*h)
+}
+
+// hueAngle returns the hue angle in degrees, in the range [0, 360).
+func hueAngle(b, a float64) float64 {
+	if a == 0 && b == 0 {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
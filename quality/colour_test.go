@@ -0,0 +1,55 @@
+package quality
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDeltaE2000(t *testing.T) {
+	// Test data from Sharma, Wu and Dalal (2005), table 1.
+	tests := []struct {
+		c1, c2 lab
+		want   float64
+	}{
+		{lab{50, 2.6772, -79.7751}, lab{50, 0, -82.7485}, 2.0425},
+		{lab{50, -1.3802, -84.2814}, lab{50, 0, -82.7485}, 1.0000},
+		{lab{50, 0, 0}, lab{50, -1, 2}, 2.3669},
+		{lab{50, 2.49, -0.001}, lab{50, -2.49, 0.0009}, 7.1792},
+		{lab{50, -0.001, 2.49}, lab{50, 0.0009, -2.49}, 4.8045},
+		{lab{50, 2.5, 0}, lab{73, 25, -18}, 27.1492},
+		{lab{50, 2.5, 0}, lab{61, -5, 29}, 22.8977},
+		{lab{50, 2.5, 0}, lab{56, -27, -3}, 31.9030},
+		{lab{50, 2.5, 0}, lab{58, 24, 15}, 19.4535},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v-%v", tt.c1, tt.c2), func(t *testing.T) {
+			got := deltaE2000(tt.c1, tt.c2)
+			if math.Abs(got-tt.want) > 1e-4 {
+				t.Errorf("deltaE2000 mismatch: got %.4f, want %.4f", got, tt.want)
+			}
+			if back := deltaE2000(tt.c2, tt.c1); math.Abs(back-got) > 1e-9 {
+				t.Errorf("deltaE2000 should be symmetric: got %.4f and %.4f", got, back)
+			}
+		})
+	}
+}
+
+func TestLinearToLab(t *testing.T) {
+	tests := []struct {
+		rgb  [3]float64
+		want lab
+	}{
+		{[3]float64{0, 0, 0}, lab{0, 0, 0}},
+		{[3]float64{1, 1, 1}, lab{100, 0, 0}},
+		{[3]float64{1, 0, 0}, lab{53.2408, 80.0925, 67.2032}},
+	}
+
+	for _, tt := range tests {
+		got := linearToLab(tt.rgb)
+		if math.Abs(got.L-tt.want.L) > 1e-2 || math.Abs(got.A-tt.want.A) > 1e-2 || math.Abs(got.B-tt.want.B) > 1e-2 {
+			t.Errorf("linearToLab(%v) = %v, want %v", tt.rgb, got, tt.want)
+		}
+	}
+}
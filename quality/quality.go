@@ -0,0 +1,229 @@
+// Package quality measures how faithfully a blurhash placeholder
+// represents its source image.
+//
+// Both images are resampled to a common size before comparison, so a
+// placeholder decoded at any resolution can be compared with its source.
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+// DefaultSize is the length of the longer side of the grid at which
+// images are compared when no size is given.
+const DefaultSize = 64
+
+// ErrEmptyImage is returned when an image to compare has no pixels.
+var ErrEmptyImage = errors.New("quality: image has no pixels")
+
+// Report holds metrics comparing a placeholder with its source image.
+type Report struct {
+	// Width and Height are the size at which the images were compared.
+	Width, Height int
+
+	// PSNR is the peak signal-to-noise ratio of the 8-bit sRGB channels,
+	// in decibels. Higher is better; identical images give +Inf.
+	PSNR float64
+
+	// SSIM is the mean structural similarity index of the luma channels,
+	// in the range [-1, 1]. Higher is better; identical images give 1.
+	SSIM float64
+
+	// MeanDeltaE and P95DeltaE are the mean and 95th percentile
+	// CIEDE2000 colour differences between corresponding pixels.
+	// A difference around 1 is barely noticeable.
+	MeanDeltaE, P95DeltaE float64
+
+	// ColourError is the CIEDE2000 difference between the mean colours
+	// of the two images, which catches placeholders with the wrong
+	// overall tint even when their structure is plausible.
+	ColourError float64
+}
+
+// Compare resamples src and placeholder to a common size, whose longer
+// side is size pixels (or DefaultSize if size is not positive), and
+// reports how closely they match. The aspect ratio of src is used, and
+// src is never upsampled.
+func Compare(src, placeholder image.Image, size int) (Report, error) {
+	width, height, err := compareSize(src, size)
+	if err != nil {
+		return Report{}, err
+	}
+	if placeholder.Bounds().Empty() {
+		return Report{}, fmt.Errorf("%w: placeholder", ErrEmptyImage)
+	}
+	return compare(resample(src, width, height), resample(placeholder, width, height), width, height), nil
+}
+
+// CompareHash decodes hash directly at the comparison size, as Compare
+// would choose it, and reports how closely it matches src.
+func CompareHash(src image.Image, hash string, punch float64, size int) (Report, error) {
+	width, height, err := compareSize(src, size)
+	if err != nil {
+		return Report{}, err
+	}
+	placeholder := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if err := blurhash.DecodeDraw(placeholder, hash, punch); err != nil {
+		return Report{}, err
+	}
+	return compare(resample(src, width, height), resample(placeholder, width, height), width, height), nil
+}
+
+// compareSize returns the comparison grid size for src.
+func compareSize(src image.Image, size int) (width, height int, err error) {
+	b := src.Bounds()
+	if b.Empty() {
+		return 0, 0, fmt.Errorf("%w: source", ErrEmptyImage)
+	}
+	if size <= 0 {
+		size = DefaultSize
+	}
+
+	width, height = b.Dx(), b.Dy()
+	if width <= size && height <= size {
+		return width, height, nil
+	}
+	if width >= height {
+		height = int(math.Max(1, math.Round(float64(height)*float64(size)/float64(width))))
+		width = size
+	} else {
+		width = int(math.Max(1, math.Round(float64(width)*float64(size)/float64(height))))
+		height = size
+	}
+	return width, height, nil
+}
+
+// resample box filters img in linear light to the given size, returning
+// linear RGB values. Upsampling uses the nearest source pixel.
+func resample(img image.Image, width, height int) [][3]float64 {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	out := make([][3]float64, width*height)
+
+	for ty := 0; ty < height; ty++ {
+		y0 := ty * sh / height
+		y1 := (ty + 1) * sh / height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for tx := 0; tx < width; tx++ {
+			x0 := tx * sw / width
+			x1 := (tx + 1) * sw / width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum [3]float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					c := color.NRGBAModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+					sum[0] += srgbToLinear[c.R]
+					sum[1] += srgbToLinear[c.G]
+					sum[2] += srgbToLinear[c.B]
+				}
+			}
+			n := float64((x1 - x0) * (y1 - y0))
+			out[ty*width+tx] = [3]float64{sum[0] / n, sum[1] / n, sum[2] / n}
+		}
+	}
+	return out
+}
+
+func compare(a, b [][3]float64, width, height int) Report {
+	r := Report{Width: width, Height: height}
+
+	var sqErr float64
+	var meanA, meanB [3]float64
+	deltaE := make([]float64, len(a))
+	lumaA := make([]float64, len(a))
+	lumaB := make([]float64, len(b))
+	for i := range a {
+		var sa, sb [3]float64
+		for c := 0; c < 3; c++ {
+			sa[c], sb[c] = linearToSRGB(a[i][c]), linearToSRGB(b[i][c])
+			d := sa[c] - sb[c]
+			sqErr += d * d
+			meanA[c] += a[i][c] / float64(len(a))
+			meanB[c] += b[i][c] / float64(len(b))
+		}
+		lumaA[i] = 0.299*sa[0] + 0.587*sa[1] + 0.114*sa[2]
+		lumaB[i] = 0.299*sb[0] + 0.587*sb[1] + 0.114*sb[2]
+
+		deltaE[i] = deltaE2000(linearToLab(a[i]), linearToLab(b[i]))
+		r.MeanDeltaE += deltaE[i] / float64(len(a))
+	}
+
+	mse := sqErr / float64(3*len(a))
+	r.PSNR = 10 * math.Log10(255*255/mse)
+
+	sort.Float64s(deltaE)
+	r.P95DeltaE = deltaE[int(math.Ceil(0.95*float64(len(deltaE))))-1]
+	r.ColourError = deltaE2000(linearToLab(meanA), linearToLab(meanB))
+	r.SSIM = ssim(lumaA, lumaB, width, height)
+
+	return r
+}
+
+// ssimWindow is the width of the Gaussian window used by ssim, with
+// standard deviation ssimSigma, following Wang et al. (2004).
+const (
+	ssimWindow = 11
+	ssimSigma  = 1.5
+)
+
+// ssim returns the mean structural similarity of two luma planes with
+// values in the range [0, 255]. Windows are clipped to the image, so
+// images smaller than the window are still measured.
+func ssim(a, b []float64, width, height int) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	var kernel [ssimWindow]float64
+	for i := range kernel {
+		d := float64(i - ssimWindow/2)
+		kernel[i] = math.Exp(-d * d / (2 * ssimSigma * ssimSigma))
+	}
+
+	var total float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var w, muA, muB, aa, bb, ab float64
+			for ky := 0; ky < ssimWindow; ky++ {
+				sy := y + ky - ssimWindow/2
+				if sy < 0 || sy >= height {
+					continue
+				}
+				for kx := 0; kx < ssimWindow; kx++ {
+					sx := x + kx - ssimWindow/2
+					if sx < 0 || sx >= width {
+						continue
+					}
+					k := kernel[ky] * kernel[kx]
+					va, vb := a[sy*width+sx], b[sy*width+sx]
+					w += k
+					muA += k * va
+					muB += k * vb
+					aa += k * va * va
+					bb += k * vb * vb
+					ab += k * va * vb
+				}
+			}
+			muA, muB = muA/w, muB/w
+			varA := aa/w - muA*muA
+			varB := bb/w - muB*muB
+			cov := ab/w - muA*muB
+			total += ((2*muA*muB + c1) * (2*cov + c2)) /
+				((muA*muA + muB*muB + c1) * (varA + varB + c2))
+		}
+	}
+	return total / float64(width*height)
+}
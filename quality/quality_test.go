@@ -0,0 +1,157 @@
+package quality_test
+
+import (
+	"errors"
+	"image"
+	_ "image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+	"github.com/bbrks/go-blurhash/quality"
+)
+
+var fixtures = []struct {
+	file string
+	hash string
+}{
+	{"../fixtures/test.png", "LFE.@D9F01_2%L%MIVD*9Goe-;WB"},
+	{"../fixtures/octocat.png", "LNAdApj[00aymkj[TKay9}ay-Sj["},
+	{"../fixtures/dalle.png", "eaF#5R0#WBjYR+58-nWCWBn~bIsTbbayjFWof8jFj[WX-nNHR*jss."},
+}
+
+func loadImage(t testing.TB, file string) image.Image {
+	t.Helper()
+	f, err := os.Open(filepath.FromSlash(file))
+	if err != nil {
+		t.Fatalf("error opening test fixture file: %v", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("error decoding image from test fixture: %v", err)
+	}
+	return img
+}
+
+func TestCompareIdentical(t *testing.T) {
+	img := loadImage(t, fixtures[0].file)
+
+	r, err := quality.Compare(img, img, 0)
+	if err != nil {
+		t.Fatalf("compare error: %v", err)
+	}
+	if !math.IsInf(r.PSNR, 1) {
+		t.Errorf("identical images should have infinite PSNR, got %v", r.PSNR)
+	}
+	if math.Abs(r.SSIM-1) > 1e-9 {
+		t.Errorf("identical images should have SSIM 1, got %v", r.SSIM)
+	}
+	if r.MeanDeltaE != 0 || r.P95DeltaE != 0 || r.ColourError != 0 {
+		t.Errorf("identical images should have no colour difference, got %+v", r)
+	}
+}
+
+func TestCompareSize(t *testing.T) {
+	img := loadImage(t, fixtures[0].file)
+	b := img.Bounds()
+
+	r, err := quality.Compare(img, img, 0)
+	if err != nil {
+		t.Fatalf("compare error: %v", err)
+	}
+	longer := r.Width
+	if r.Height > longer {
+		longer = r.Height
+	}
+	if longer != quality.DefaultSize {
+		t.Errorf("longer side should be %d, got %dx%d", quality.DefaultSize, r.Width, r.Height)
+	}
+	gotAspect := float64(r.Width) / float64(r.Height)
+	wantAspect := float64(b.Dx()) / float64(b.Dy())
+	if math.Abs(gotAspect-wantAspect) > 0.05 {
+		t.Errorf("comparison should keep the source aspect ratio: got %v, want %v", gotAspect, wantAspect)
+	}
+
+	small := image.NewNRGBA(image.Rect(0, 0, 5, 3))
+	r, err = quality.Compare(small, small, 0)
+	if err != nil {
+		t.Fatalf("compare error: %v", err)
+	}
+	if r.Width != 5 || r.Height != 3 {
+		t.Errorf("small sources should not be upsampled, got %dx%d", r.Width, r.Height)
+	}
+}
+
+func TestCompareHash(t *testing.T) {
+	for i, test := range fixtures {
+		t.Run(test.file, func(t *testing.T) {
+			img := loadImage(t, test.file)
+
+			own, err := quality.CompareHash(img, test.hash, 1, 0)
+			if err != nil {
+				t.Fatalf("compare error: %v", err)
+			}
+			t.Logf("%+v", own)
+
+			// A placeholder of a different image should score worse.
+			other := fixtures[(i+1)%len(fixtures)].hash
+			wrong, err := quality.CompareHash(img, other, 1, 0)
+			if err != nil {
+				t.Fatalf("compare error: %v", err)
+			}
+			if own.PSNR <= wrong.PSNR {
+				t.Errorf("own hash should have higher PSNR: got %v, other %v", own.PSNR, wrong.PSNR)
+			}
+			if own.MeanDeltaE >= wrong.MeanDeltaE {
+				t.Errorf("own hash should have lower mean deltaE: got %v, other %v", own.MeanDeltaE, wrong.MeanDeltaE)
+			}
+			if own.ColourError >= wrong.ColourError {
+				t.Errorf("own hash should have lower colour error: got %v, other %v", own.ColourError, wrong.ColourError)
+			}
+			if own.P95DeltaE < own.MeanDeltaE {
+				t.Errorf("95th percentile %v should not be below the mean %v", own.P95DeltaE, own.MeanDeltaE)
+			}
+
+			// Comparing a placeholder decoded at another size gives
+			// similar results, as both are resampled.
+			placeholder, err := blurhash.Decode(test.hash, 32, 32, 1)
+			if err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			resampled, err := quality.Compare(img, placeholder, 0)
+			if err != nil {
+				t.Fatalf("compare error: %v", err)
+			}
+			if math.Abs(resampled.ColourError-own.ColourError) > 1 {
+				t.Errorf("colour error should not depend on placeholder size: got %v, want ~%v", resampled.ColourError, own.ColourError)
+			}
+		})
+	}
+}
+
+func TestCompareErrors(t *testing.T) {
+	img := loadImage(t, fixtures[0].file)
+	empty := image.NewNRGBA(image.Rectangle{})
+
+	if _, err := quality.Compare(empty, img, 0); !errors.Is(err, quality.ErrEmptyImage) {
+		t.Errorf("empty source: expected ErrEmptyImage, got %v", err)
+	}
+	if _, err := quality.Compare(img, empty, 0); !errors.Is(err, quality.ErrEmptyImage) {
+		t.Errorf("empty placeholder: expected ErrEmptyImage, got %v", err)
+	}
+	if _, err := quality.CompareHash(img, "invalid", 1, 0); !errors.Is(err, blurhash.ErrInvalidHash) {
+		t.Errorf("invalid hash: expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func BenchmarkCompareHash(b *testing.B) {
+	img := loadImage(b, fixtures[0].file)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = quality.CompareHash(img, fixtures[0].hash, 1, 0)
+	}
+}
@@ -0,0 +1,418 @@
+package blurhash
+
+import "math"
+
+// Optimisation selects how an Encoder chooses the quantised values it
+// writes to a hash. Every mode produces a standard hash that any blurhash
+// decoder understands; only the choice of values differs.
+type Optimisation int
+
+const (
+	// OptimiseNone quantises each value independently with the standard
+	// rounding rule used by the reference implementation.
+	OptimiseNone Optimisation = iota
+
+	// OptimiseLinear searches every quantised maximum AC value and picks
+	// the code for each component channel that minimises the squared
+	// reconstruction error of the placeholder in linear RGB.
+	OptimiseLinear
+
+	// OptimiseSRGB refines the OptimiseLinear result to minimise the
+	// squared error between the decoded placeholder and a downsample of
+	// the source image in sRGB. It is considerably slower than
+	// OptimiseLinear, as candidates are rendered and compared.
+	OptimiseSRGB
+
+	// OptimiseOklab refines as OptimiseSRGB does, but measures error in
+	// the perceptually uniform Oklab space. This favours hashes that look
+	// closest to the source, avoiding the muddy, desaturated colours that
+	// averaging in linear RGB tends to produce for vivid images.
+	OptimiseOklab
+)
+
+// rendered reports whether o compares rendered candidates against the source.
+func (o Optimisation) rendered() bool {
+	return o == OptimiseSRGB || o == OptimiseOklab
+}
+
+// space returns the colour space in which o measures rendered error.
+func (o Optimisation) space() colorSpace {
+	if o == OptimiseOklab {
+		return spaceOklab
+	}
+	return spaceSRGB
+}
+
+// quantHash holds the quantised values of a hash before serialisation.
+type quantHash struct {
+	xComponents, yComponents int
+
+	// maximum is the quantised maximum AC value, 0-82.
+	maximum int
+	// values[0] is the sRGB DC colour, 0-255 per channel.
+	// The remaining values are AC codes, 0-18 per channel.
+	values [][3]int
+}
+
+// colors decodes the linear coefficients represented by q into colors, as
+// a decoder would with a punch of 1.
+func (q *quantHash) colors(colors [][3]float64) {
+	maximumValue := float64(q.maximum+1) / 166
+	dc := q.values[0]
+	colors[0] = [3]float64{sRGBToLinear(dc[0]), sRGBToLinear(dc[1]), sRGBToLinear(dc[2])}
+	for k, v := range q.values[1:] {
+		colors[k+1] = [3]float64{
+			acValue(v[0]) * maximumValue,
+			acValue(v[1]) * maximumValue,
+			acValue(v[2]) * maximumValue,
+		}
+	}
+}
+
+// acValue returns the normalised AC value, in the range [-1, 1], of a 0-18 code.
+func acValue(code int) float64 {
+	n := float64(code-9) / 9
+	return n * math.Abs(n)
+}
+
+// nearestAC returns the code whose decoded value is closest to v.
+func nearestAC(v, maximumValue float64) int {
+	lo := int(math.Max(0, math.Min(18, math.Floor(signSqrt(v/maximumValue)*9+9))))
+	if lo == 18 {
+		return lo
+	}
+	if math.Abs(acValue(lo+1)*maximumValue-v) < math.Abs(acValue(lo)*maximumValue-v) {
+		return lo + 1
+	}
+	return lo
+}
+
+// nearestDC returns the sRGB value whose linear value is closest to v.
+func nearestDC(v float64) int {
+	best := linearToSRGB(v)
+	bestErr := math.Abs(sRGBToLinear(best) - v)
+	for _, c := range []int{best - 1, best + 1} {
+		if c < 0 || c > 255 {
+			continue
+		}
+		if err := math.Abs(sRGBToLinear(c) - v); err < bestErr {
+			best, bestErr = c, err
+		}
+	}
+	return best
+}
+
+// componentWeight returns the mean squared value of the basis function of
+// component (i, j), which scales its coefficient error into pixel error.
+func componentWeight(i, j int) float64 {
+	w := 1.0
+	if i > 0 {
+		w /= 2
+	}
+	if j > 0 {
+		w /= 2
+	}
+	return w
+}
+
+// optimiseLinear updates q to the quantisation of factors which minimises
+// the squared reconstruction error in linear RGB. As the basis functions
+// are orthogonal, this error is a weighted sum of coefficient errors, so
+// for each candidate maximum every channel can be chosen independently.
+func optimiseLinear(q *quantHash, factors [][3]float64) {
+	for c := 0; c < 3; c++ {
+		q.values[0][c] = nearestDC(factors[0][c])
+	}
+	if len(factors) == 1 {
+		return
+	}
+
+	best, bestErr := q.maximum, math.Inf(1)
+	for m := 0; m <= 82; m++ {
+		maximumValue := float64(m+1) / 166
+		var sum float64
+		for k, f := range factors[1:] {
+			w := componentWeight((k+1)%q.xComponents, (k+1)/q.xComponents)
+			for c := 0; c < 3; c++ {
+				d := acValue(nearestAC(f[c], maximumValue))*maximumValue - f[c]
+				sum += w * d * d
+			}
+		}
+		if sum < bestErr {
+			best, bestErr = m, sum
+		}
+	}
+
+	q.maximum = best
+	maximumValue := float64(best+1) / 166
+	for k, f := range factors[1:] {
+		for c := 0; c < 3; c++ {
+			q.values[k+1][c] = nearestAC(f[c], maximumValue)
+		}
+	}
+}
+
+// colorSpace identifies a space in which reconstruction error is measured.
+type colorSpace int
+
+const (
+	spaceLinear colorSpace = iota
+	spaceSRGB
+	spaceOklab
+)
+
+// convert clamps a linear RGB colour to the displayable range, as a
+// decoder does, and converts it to the colour space.
+func (s colorSpace) convert(c [3]float64) [3]float64 {
+	for i, v := range c {
+		c[i] = math.Max(0, math.Min(1, v))
+	}
+	switch s {
+	case spaceSRGB:
+		for i, v := range c {
+			c[i] = linearToSRGBFloat(v)
+		}
+	case spaceOklab:
+		c = linearToOklab(c)
+	}
+	return c
+}
+
+// linearToOklab converts a linear sRGB colour to Oklab.
+// See https://bottosson.github.io/posts/oklab/
+func linearToOklab(c [3]float64) [3]float64 {
+	l := math.Cbrt(0.4122214708*c[0] + 0.5363325363*c[1] + 0.0514459929*c[2])
+	m := math.Cbrt(0.2119034982*c[0] + 0.6806995451*c[1] + 0.1073969566*c[2])
+	s := math.Cbrt(0.0883024619*c[0] + 0.2817188376*c[1] + 0.6299787005*c[2])
+	return [3]float64{
+		0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+		1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+		0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+	}
+}
+
+// linearToSRGBFloat applies the exact sRGB transfer function to a linear
+// value in the range [0, 1], returning a value in the same range.
+func linearToSRGBFloat(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// gridSize is the maximum width and height of the downsampled source grid
+// against which decoded candidates are compared. It comfortably samples
+// the highest frequency representable with 9 components.
+const gridSize = 24
+
+// downsample box filters the source pixels in linear RGB into
+// e.linearTarget, and computes the basis functions sampled at the centre
+// of each target cell.
+func (e *Encoder) downsample(pix []uint8, stride int, layout packedLayout, width, height int) {
+	gw, gh := width, height
+	if gw > gridSize {
+		gw = gridSize
+	}
+	if gh > gridSize {
+		gh = gridSize
+	}
+	e.gridWidth, e.gridHeight = gw, gh
+
+	e.linearTarget = growTo(e.linearTarget, gw*gh)
+	e.counts = growTo(e.counts, gw*gh)
+	for i := range e.linearTarget {
+		e.linearTarget[i] = [3]float64{}
+		e.counts[i] = 0
+	}
+	for y := 0; y < height; y++ {
+		row := (y * gh / height) * gw
+		for x := 0; x < width; x++ {
+			cell := row + x*gw/width
+			i := y*stride + x*layout.size
+			e.linearTarget[cell][0] += sRGBToLinear(int(pix[i+layout.r]))
+			e.linearTarget[cell][1] += sRGBToLinear(int(pix[i+layout.g]))
+			e.linearTarget[cell][2] += sRGBToLinear(int(pix[i+layout.b]))
+			e.counts[cell]++
+		}
+	}
+	for i, t := range e.linearTarget {
+		n := float64(e.counts[i])
+		e.linearTarget[i] = [3]float64{t[0] / n, t[1] / n, t[2] / n}
+	}
+	e.targetValid = false
+
+	e.gridCosX = growTo(e.gridCosX, maxComponents*gw)
+	e.gridCosY = growTo(e.gridCosY, maxComponents*gh)
+	for i := 0; i < maxComponents; i++ {
+		for x := 0; x < gw; x++ {
+			centre := (float64(x)+0.5)*float64(width)/float64(gw) - 0.5
+			e.gridCosX[i*gw+x] = math.Cos(math.Pi * float64(i) * centre / float64(width))
+		}
+	}
+	for j := 0; j < maxComponents; j++ {
+		for y := 0; y < gh; y++ {
+			centre := (float64(y)+0.5)*float64(height)/float64(gh) - 0.5
+			e.gridCosY[j*gh+y] = math.Cos(math.Pi * float64(j) * centre / float64(height))
+		}
+	}
+}
+
+// renderGrid renders the linear reconstruction of colors over the target grid into e.render.
+func (e *Encoder) renderGrid(xComponents, yComponents int, colors [][3]float64) {
+	gw, gh := e.gridWidth, e.gridHeight
+	e.render = growTo(e.render, gw*gh)
+	for y := 0; y < gh; y++ {
+		for x := 0; x < gw; x++ {
+			var c [3]float64
+			for j := 0; j < yComponents; j++ {
+				basisY := e.gridCosY[j*gh+y]
+				for i := 0; i < xComponents; i++ {
+					basis := e.gridCosX[i*gw+x] * basisY
+					f := colors[j*xComponents+i]
+					c[0] += f[0] * basis
+					c[1] += f[1] * basis
+					c[2] += f[2] * basis
+				}
+			}
+			e.render[y*gw+x] = c
+		}
+	}
+}
+
+// useSpace converts e.linearTarget into e.target in the given colour
+// space, unless it has already been converted.
+func (e *Encoder) useSpace(space colorSpace) {
+	if e.targetValid && e.targetSpace == space {
+		return
+	}
+	e.target = growTo(e.target, len(e.linearTarget))
+	for i, t := range e.linearTarget {
+		e.target[i] = space.convert(t)
+	}
+	e.targetSpace, e.targetValid = space, true
+}
+
+// gridError returns the summed squared distance, in the given colour
+// space, between e.render and e.target.
+func (e *Encoder) gridError(space colorSpace) float64 {
+	e.useSpace(space)
+	var sum float64
+	for i, c := range e.render {
+		c = space.convert(c)
+		t := e.target[i]
+		for ch := 0; ch < 3; ch++ {
+			d := c[ch] - t[ch]
+			sum += d * d
+		}
+	}
+	return sum
+}
+
+// maxRefinePasses bounds the number of coordinate descent passes made by optimiseGrid.
+const maxRefinePasses = 4
+
+// optimiseGrid updates q, which must hold the result of optimiseLinear, to
+// reduce the error of its decoded reconstruction against e.target in the
+// given colour space. The standard quantisation std is kept if nothing
+// better is found.
+func (e *Encoder) optimiseGrid(q, std *quantHash, factors [][3]float64, space colorSpace) {
+	n := q.xComponents * q.yComponents
+	e.colors = growTo(e.colors, n)
+
+	std.colors(e.colors)
+	e.renderGrid(q.xComponents, q.yComponents, e.colors)
+	stdErr := e.gridError(space)
+
+	// Choose the maximum AC value by rendering each candidate with its
+	// nearest codes, as clamping and the transfer function make the
+	// linear optimum only a starting point.
+	if n > 1 {
+		best, bestErr := q.maximum, math.Inf(1)
+		for m := 0; m <= 82; m++ {
+			setMaximum(q, factors, m)
+			q.colors(e.colors)
+			e.renderGrid(q.xComponents, q.yComponents, e.colors)
+			if err := e.gridError(space); err < bestErr {
+				best, bestErr = m, err
+			}
+		}
+		setMaximum(q, factors, best)
+	}
+
+	q.colors(e.colors)
+	e.renderGrid(q.xComponents, q.yComponents, e.colors)
+	err := e.gridError(space)
+
+	// Coordinate descent: nudge each channel code up or down while doing
+	// so reduces the error.
+	gw, gh := e.gridWidth, e.gridHeight
+	for pass := 0; pass < maxRefinePasses; pass++ {
+		improved := false
+		for k := 0; k < n; k++ {
+			i, j := k%q.xComponents, k/q.xComponents
+			for ch := 0; ch < 3; ch++ {
+				for _, step := range []int{-1, 1} {
+					old := q.values[k][ch]
+					limit := 18
+					if k == 0 {
+						limit = 255
+					}
+					if old+step < 0 || old+step > limit {
+						continue
+					}
+
+					q.values[k][ch] = old + step
+					prev := e.colors[k][ch]
+					next := q.value(k, ch)
+					e.addBasis(i, j, ch, next-prev, gw, gh)
+					if candidate := e.gridError(space); candidate < err {
+						err = candidate
+						e.colors[k][ch] = next
+						improved = true
+						continue
+					}
+					e.addBasis(i, j, ch, prev-next, gw, gh)
+					q.values[k][ch] = old
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	if stdErr <= err {
+		q.maximum = std.maximum
+		copy(q.values, std.values)
+	}
+}
+
+// setMaximum sets the maximum AC value of q and requantises the AC codes
+// to the nearest values for factors.
+func setMaximum(q *quantHash, factors [][3]float64, m int) {
+	q.maximum = m
+	maximumValue := float64(m+1) / 166
+	for k, f := range factors[1:] {
+		for c := 0; c < 3; c++ {
+			q.values[k+1][c] = nearestAC(f[c], maximumValue)
+		}
+	}
+}
+
+// value returns the linear coefficient of channel ch of component k.
+func (q *quantHash) value(k, ch int) float64 {
+	if k == 0 {
+		return sRGBToLinear(q.values[0][ch])
+	}
+	return acValue(q.values[k][ch]) * float64(q.maximum+1) / 166
+}
+
+// addBasis adds delta times the basis function of component (i, j) to channel ch of e.render.
+func (e *Encoder) addBasis(i, j, ch int, delta float64, gw, gh int) {
+	for y := 0; y < gh; y++ {
+		basisY := e.gridCosY[j*gh+y] * delta
+		for x := 0; x < gw; x++ {
+			e.render[y*gw+x][ch] += e.gridCosX[i*gw+x] * basisY
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package blurhash
+
+import (
+	"image"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var quantiseFixtures = []struct {
+	file         string
+	xComp, yComp int
+}{
+	{"fixtures/test.png", 4, 3},
+	{"fixtures/octocat.png", 4, 3},
+	{"fixtures/dalle.png", 5, 5},
+	{"fixtures/octocat.png", 1, 1},
+}
+
+func loadFixture(t testing.TB, file string) image.Image {
+	t.Helper()
+	f, err := os.Open(filepath.FromSlash(file))
+	if err != nil {
+		t.Fatalf("error opening test fixture file: %v", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		t.Fatalf("error decoding image from test fixture: %v", err)
+	}
+	return img
+}
+
+// linearError returns the weighted squared coefficient error of hash
+// against factors, which is proportional to its linear RGB pixel error.
+func linearError(t *testing.T, hash string, xComp int, factors [][3]float64) float64 {
+	t.Helper()
+	colors := make([][3]float64, len(factors))
+	if err := decodeColors(colors, hash, 1, ProfileV12); err != nil {
+		t.Fatalf("error decoding hash: %v", err)
+	}
+	var sum float64
+	for k, f := range factors {
+		w := componentWeight(k%xComp, k/xComp)
+		for c := 0; c < 3; c++ {
+			d := colors[k][c] - f[c]
+			sum += w * d * d
+		}
+	}
+	return sum
+}
+
+// renderedError returns the squared error of hash against a downsample of
+// img, measured in the colour space used by the given optimisation.
+func renderedError(t *testing.T, hash string, xComp, yComp int, img image.Image, opt Optimisation) float64 {
+	t.Helper()
+	e := Encoder{Optimise: opt}
+	if err := e.computeFactors(xComp, yComp, img, true); err != nil {
+		t.Fatalf("error computing factors: %v", err)
+	}
+	colors := make([][3]float64, xComp*yComp)
+	if err := decodeColors(colors, hash, 1, ProfileV12); err != nil {
+		t.Fatalf("error decoding hash: %v", err)
+	}
+	e.renderGrid(xComp, yComp, colors)
+	return e.gridError(opt.space())
+}
+
+func TestEncoderOptimise(t *testing.T) {
+	for _, test := range quantiseFixtures {
+		t.Run(test.file, func(t *testing.T) {
+			img := loadFixture(t, test.file)
+
+			std, err := Encode(test.xComp, test.yComp, img)
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+
+			none := Encoder{Optimise: OptimiseNone}
+			hash, err := none.Encode(test.xComp, test.yComp, img)
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+			if hash != std {
+				t.Errorf("OptimiseNone should match Encode: got %q, want %q", hash, std)
+			}
+
+			linear := Encoder{Optimise: OptimiseLinear}
+			linearHash, err := linear.Encode(test.xComp, test.yComp, img)
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+			if got, want := linearError(t, linearHash, test.xComp, linear.factors), linearError(t, std, test.xComp, linear.factors); got > want {
+				t.Errorf("OptimiseLinear should not increase linear error: got %v, standard %v", got, want)
+			}
+
+			srgb := Encoder{Optimise: OptimiseSRGB}
+			srgbHash, err := srgb.Encode(test.xComp, test.yComp, img)
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+			if got, want := renderedError(t, srgbHash, test.xComp, test.yComp, img, OptimiseSRGB), renderedError(t, std, test.xComp, test.yComp, img, OptimiseSRGB); got > want {
+				t.Errorf("OptimiseSRGB should not increase sRGB error: got %v, standard %v", got, want)
+			}
+
+			for _, h := range []string{linearHash, srgbHash} {
+				x, y, err := Components(h)
+				if err != nil || x != test.xComp || y != test.yComp {
+					t.Errorf("optimised hash %q should be standard: got x=%d, y=%d, err=%v", h, x, y, err)
+				}
+				if _, err := Decode(h, 8, 8, 1); err != nil {
+					t.Errorf("optimised hash %q should decode: %v", h, err)
+				}
+			}
+		})
+	}
+}
+
+func TestEncoderOptimiseOklab(t *testing.T) {
+	var total, totalStd float64
+	for _, test := range quantiseFixtures {
+		t.Run(test.file, func(t *testing.T) {
+			img := loadFixture(t, test.file)
+
+			std, err := Encode(test.xComp, test.yComp, img)
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+			e := Encoder{Optimise: OptimiseOklab}
+			hash, err := e.Encode(test.xComp, test.yComp, img)
+			if err != nil {
+				t.Fatalf("encode error: %v", err)
+			}
+			if _, err := Decode(hash, 8, 8, 1); err != nil {
+				t.Errorf("optimised hash %q should decode: %v", hash, err)
+			}
+
+			got := renderedError(t, hash, test.xComp, test.yComp, img, OptimiseOklab)
+			want := renderedError(t, std, test.xComp, test.yComp, img, OptimiseOklab)
+			if got > want {
+				t.Errorf("OptimiseOklab should not increase Oklab error: got %v, standard %v", got, want)
+			}
+			t.Logf("Oklab error %.4f (standard %.4f): %s (standard %s)", got, want, hash, std)
+			total += got
+			totalStd += want
+		})
+	}
+	// Across the fixtures the perceptual search should be a clear win,
+	// not merely a tie with the standard quantisation.
+	if total > 0.95*totalStd {
+		t.Errorf("OptimiseOklab should reduce total Oklab error by at least 5%%: got %.4f, standard %.4f", total, totalStd)
+	}
+}
+
+func TestLinearToOklab(t *testing.T) {
+	// Reference values from https://bottosson.github.io/posts/oklab/
+	tests := []struct {
+		rgb  [3]float64
+		want [3]float64
+	}{
+		{[3]float64{1, 1, 1}, [3]float64{1, 0, 0}},
+		{[3]float64{1, 0, 0}, [3]float64{0.627955, 0.224863, 0.125846}},
+		{[3]float64{0, 1, 0}, [3]float64{0.866440, -0.233888, 0.179498}},
+		{[3]float64{0, 0, 1}, [3]float64{0.452014, -0.032457, -0.311528}},
+	}
+	for _, tt := range tests {
+		got := linearToOklab(tt.rgb)
+		for i := range got {
+			if absFloat(got[i]-tt.want[i]) > 1e-4 {
+				t.Errorf("linearToOklab(%v) = %v, want %v", tt.rgb, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestNearestAC(t *testing.T) {
+	for _, maximumValue := range []float64{1.0 / 166, 0.25, 0.5} {
+		for v := -1.2 * maximumValue; v <= 1.2*maximumValue; v += maximumValue / 97 {
+			got := nearestAC(v, maximumValue)
+			gotErr := absFloat(acValue(got)*maximumValue - v)
+			for code := 0; code <= 18; code++ {
+				if err := absFloat(acValue(code)*maximumValue - v); err < gotErr {
+					t.Fatalf("nearestAC(%v, %v) = %d, but %d is closer", v, maximumValue, got, code)
+				}
+			}
+		}
+	}
+}
+
+func TestNearestDC(t *testing.T) {
+	for v := 0.0; v <= 1; v += 1.0 / 1021 {
+		got := nearestDC(v)
+		gotErr := absFloat(sRGBToLinear(got) - v)
+		for c := 0; c <= 255; c++ {
+			if err := absFloat(sRGBToLinear(c) - v); err < gotErr {
+				t.Fatalf("nearestDC(%v) = %d, but %d is closer", v, got, c)
+			}
+		}
+	}
+}
+
+func absFloat(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func BenchmarkEncoderOptimise(b *testing.B) {
+	img := loadFixture(b, "fixtures/test.png")
+	for _, mode := range []struct {
+		name string
+		opt  Optimisation
+	}{
+		{"none", OptimiseNone},
+		{"linear", OptimiseLinear},
+		{"srgb", OptimiseSRGB},
+		{"oklab", OptimiseOklab},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			e := Encoder{Optimise: mode.opt}
+			for i := 0; i < b.N; i++ {
+				_, _ = e.Encode(4, 3, img)
+			}
+		})
+	}
+}
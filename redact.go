@@ -0,0 +1,207 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// Redactor obscures regions of an image in place, replacing each region
+// with the decoded blurhash of its own pixels at full resolution. It
+// suits moderation tooling that censors faces or licence plates while
+// keeping the overall colour of the image.
+//
+// A Redactor is safe for sequential use but not for concurrent use.
+type Redactor struct {
+	// Feather is the width in pixels of the band outside each rectangle
+	// over which the render fades into the surrounding image. The
+	// rectangle itself is always fully replaced. Zero gives hard edges.
+	Feather int
+
+	xComponents, yComponents int
+
+	enc    Encoder
+	dec    Decoder
+	render *image.NRGBA
+}
+
+// NewRedactor creates a Redactor rendering regions with the given number
+// of components.
+func NewRedactor(xComponents, yComponents int) *Redactor {
+	return &Redactor{
+		xComponents: xComponents,
+		yComponents: yComponents,
+	}
+}
+
+// Redact replaces each of the given rectangles of dst with the decoded
+// blurhash of its pixels. Rectangles are clipped to the bounds of dst and
+// processed in order, so a rectangle overlapping an earlier one encodes
+// the already redacted pixels.
+func (r *Redactor) Redact(dst draw.Image, regions ...image.Rectangle) error {
+	if err := r.check(); err != nil {
+		return err
+	}
+
+	bounds := dst.Bounds()
+	for _, region := range regions {
+		region = region.Intersect(bounds)
+		if region.Empty() {
+			continue
+		}
+		hash, err := r.enc.Encode(r.xComponents, r.yComponents, subImage(dst, region))
+		if err != nil {
+			return err
+		}
+
+		if r.Feather == 0 {
+			// Decode straight into the destination where it allows.
+			if sub, ok := subImage(dst, region).(draw.Image); ok {
+				if err := r.dec.DecodeDraw(sub, hash, 1); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if err := r.decodeRender(hash, region); err != nil {
+			return err
+		}
+		r.featherRegion(dst, region)
+	}
+	return nil
+}
+
+// RedactMask replaces the pixels of dst selected by mask with the decoded
+// blurhash of the smallest rectangle enclosing them. The mask is in the
+// coordinate space of dst, and its alpha is the weight given to the
+// render at each pixel, so a soft-edged mask gives feathered edges.
+// Feather is not applied.
+//
+// Every selected pixel shares a single render, so separate areas that
+// should not influence each other's colours are better redacted with
+// separate calls.
+func (r *Redactor) RedactMask(dst draw.Image, mask image.Image) error {
+	if err := r.check(); err != nil {
+		return err
+	}
+
+	region := maskBounds(mask, dst.Bounds())
+	if region.Empty() {
+		return nil
+	}
+	hash, err := r.enc.Encode(r.xComponents, r.yComponents, subImage(dst, region))
+	if err != nil {
+		return err
+	}
+	if err := r.decodeRender(hash, region); err != nil {
+		return err
+	}
+
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			_, _, _, a := mask.At(x, y).RGBA()
+			if a == 0 {
+				continue
+			}
+			blendPixel(dst, x, y, r.render.NRGBAAt(x-region.Min.X, y-region.Min.Y), float64(a)/0xffff)
+		}
+	}
+	return nil
+}
+
+// check returns an error if the redactor's options are invalid.
+func (r *Redactor) check() error {
+	if err := checkComponents(r.xComponents, r.yComponents); err != nil {
+		return err
+	}
+	if r.Feather < 0 {
+		return fmt.Errorf("%w: had feather=%d", ErrInvalidDimensions, r.Feather)
+	}
+	return nil
+}
+
+// decodeRender decodes hash into r.render at the size of region.
+func (r *Redactor) decodeRender(hash string, region image.Rectangle) error {
+	rect := image.Rect(0, 0, region.Dx(), region.Dy())
+	// Reuse render buffer if large enough
+	if r.render == nil || r.render.Stride < rect.Dx()*4 || len(r.render.Pix) < rect.Dy()*r.render.Stride {
+		r.render = image.NewNRGBA(rect)
+	} else {
+		r.render.Rect = rect
+	}
+	return r.dec.DecodeDraw(r.render, hash, 1)
+}
+
+// featherRegion draws r.render over region of dst, fading it out across
+// the Feather pixels surrounding the region.
+func (r *Redactor) featherRegion(dst draw.Image, region image.Rectangle) {
+	outer := region.Inset(-r.Feather).Intersect(dst.Bounds())
+	for y := outer.Min.Y; y < outer.Max.Y; y++ {
+		// Pixels outside the region take the colour of its nearest edge.
+		ry := minInt(maxInt(y, region.Min.Y), region.Max.Y-1)
+		dy := absInt(y - ry)
+		for x := outer.Min.X; x < outer.Max.X; x++ {
+			rx := minInt(maxInt(x, region.Min.X), region.Max.X-1)
+			dx := absInt(x - rx)
+
+			weight := 1.0
+			if dx != 0 || dy != 0 {
+				dist := math.Sqrt(float64(dx*dx + dy*dy))
+				weight = 1 - smoothstep(math.Min(dist/float64(r.Feather+1), 1))
+				if weight == 0 {
+					continue
+				}
+			}
+			blendPixel(dst, x, y, r.render.NRGBAAt(rx-region.Min.X, ry-region.Min.Y), weight)
+		}
+	}
+}
+
+// maskBounds returns the smallest rectangle within bounds enclosing every
+// pixel of mask with a non-zero alpha.
+func maskBounds(mask image.Image, bounds image.Rectangle) image.Rectangle {
+	bounds = bounds.Intersect(mask.Bounds())
+	var r image.Rectangle
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := mask.At(x, y).RGBA(); a != 0 {
+				r = r.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+// blendPixel blends the opaque colour c over the pixel of dst at (x, y)
+// with the given weight in [0, 1].
+func blendPixel(dst draw.Image, x, y int, c color.NRGBA, weight float64) {
+	if weight >= 1 {
+		dst.Set(x, y, c)
+		return
+	}
+	or, og, ob, oa := dst.At(x, y).RGBA()
+	mix := func(orig uint32, v uint8) uint16 {
+		return uint16(float64(orig)*(1-weight) + float64(uint32(v)*0x101)*weight + 0.5)
+	}
+	dst.Set(x, y, color.RGBA64{
+		R: mix(or, c.R),
+		G: mix(og, c.G),
+		B: mix(ob, c.B),
+		A: mix(oa, 255),
+	})
+}
+
+// Redact replaces each of the given rectangles of dst with the decoded
+// blurhash of its pixels, with hard edges.
+func Redact(dst draw.Image, xComponents, yComponents int, regions ...image.Rectangle) error {
+	return NewRedactor(xComponents, yComponents).Redact(dst, regions...)
+}
+
+// RedactMask replaces the pixels of dst selected by mask with the decoded
+// blurhash of the smallest rectangle enclosing them.
+func RedactMask(dst draw.Image, xComponents, yComponents int, mask image.Image) error {
+	return NewRedactor(xComponents, yComponents).RedactMask(dst, mask)
+}
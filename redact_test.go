@@ -0,0 +1,220 @@
+package blurhash_test
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestRedact(t *testing.T) {
+	orig := loadNRGBA(t, testFixtures[0].file)
+	region := image.Rect(40, 30, 140, 90)
+	want := renderRegion(t, orig, region)
+
+	dst := cloneNRGBA(orig)
+	if err := blurhash.Redact(dst, 4, 3, region); err != nil {
+		t.Fatalf("error redacting: %v", err)
+	}
+
+	b := orig.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			p := image.Pt(x, y)
+			got := dst.NRGBAAt(x, y)
+			if p.In(region) {
+				if w := want.NRGBAAt(x-region.Min.X, y-region.Min.Y); got != w {
+					t.Fatalf("pixel %v = %v, want render %v", p, got, w)
+				}
+			} else if o := orig.NRGBAAt(x, y); got != o {
+				t.Fatalf("pixel %v outside region changed from %v to %v", p, o, got)
+			}
+		}
+	}
+}
+
+func TestRedactFeather(t *testing.T) {
+	orig := loadNRGBA(t, testFixtures[0].file)
+	region := image.Rect(40, 30, 140, 90)
+	want := renderRegion(t, orig, region)
+
+	r := blurhash.NewRedactor(4, 3)
+	r.Feather = 8
+	dst := cloneNRGBA(orig)
+	if err := r.Redact(dst, region); err != nil {
+		t.Fatalf("error redacting: %v", err)
+	}
+
+	// The region itself is fully replaced.
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if got, w := dst.NRGBAAt(x, y), want.NRGBAAt(x-region.Min.X, y-region.Min.Y); got != w {
+				t.Fatalf("pixel (%d, %d) = %v, want render %v", x, y, got, w)
+			}
+		}
+	}
+
+	// Beyond the feather band, nothing changes.
+	outer := region.Inset(-r.Feather)
+	b := orig.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if image.Pt(x, y).In(outer) {
+				continue
+			}
+			if got, o := dst.NRGBAAt(x, y), orig.NRGBAAt(x, y); got != o {
+				t.Fatalf("pixel (%d, %d) beyond feather changed from %v to %v", x, y, o, got)
+			}
+		}
+	}
+
+	// Within the band, pixels lie between the render and the original,
+	// nearest the render beside the region.
+	y := (region.Min.Y + region.Max.Y) / 2
+	edge := want.NRGBAAt(region.Dx()-1, y-region.Min.Y)
+	for d := 1; d <= r.Feather; d++ {
+		x := region.Max.X - 1 + d
+		got, o := dst.NRGBAAt(x, y), orig.NRGBAAt(x, y)
+		fromOrig := absDiff(got.R, o.R) + absDiff(got.G, o.G) + absDiff(got.B, o.B)
+		fromEdge := absDiff(got.R, edge.R) + absDiff(got.G, edge.G) + absDiff(got.B, edge.B)
+		total := absDiff(o.R, edge.R) + absDiff(o.G, edge.G) + absDiff(o.B, edge.B)
+		if fromOrig+fromEdge > total+3 {
+			t.Errorf("feathered pixel (%d, %d) = %v is not between %v and %v", x, y, got, o, edge)
+		}
+		if d == 1 && fromEdge > fromOrig {
+			t.Errorf("feathered pixel (%d, %d) = %v is nearer the original %v than the render %v", x, y, got, o, edge)
+		}
+	}
+}
+
+func TestRedactMask(t *testing.T) {
+	orig := loadNRGBA(t, testFixtures[0].file)
+
+	// A disc, opaque at its centre and fading out over its last pixels.
+	mask := image.NewAlpha(orig.Bounds())
+	centre := image.Pt(90, 60)
+	for y := 0; y < mask.Bounds().Dy(); y++ {
+		for x := 0; x < mask.Bounds().Dx(); x++ {
+			dx, dy := x-centre.X, y-centre.Y
+			switch d2 := dx*dx + dy*dy; {
+			case d2 < 20*20:
+				mask.SetAlpha(x, y, color.Alpha{255})
+			case d2 < 24*24:
+				mask.SetAlpha(x, y, color.Alpha{128})
+			}
+		}
+	}
+	region := image.Rect(centre.X-23, centre.Y-23, centre.X+24, centre.Y+24)
+	want := renderRegion(t, orig, region)
+
+	dst := cloneNRGBA(orig)
+	if err := blurhash.RedactMask(dst, 4, 3, mask); err != nil {
+		t.Fatalf("error redacting: %v", err)
+	}
+
+	b := orig.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			got, o := dst.NRGBAAt(x, y), orig.NRGBAAt(x, y)
+			switch mask.AlphaAt(x, y).A {
+			case 0:
+				if got != o {
+					t.Fatalf("unmasked pixel (%d, %d) changed from %v to %v", x, y, o, got)
+				}
+			case 255:
+				if w := want.NRGBAAt(x-region.Min.X, y-region.Min.Y); got != w {
+					t.Fatalf("masked pixel (%d, %d) = %v, want render %v", x, y, got, w)
+				}
+			}
+		}
+	}
+}
+
+func TestRedactGenericImage(t *testing.T) {
+	orig := loadNRGBA(t, testFixtures[1].file)
+	regions := []image.Rectangle{
+		image.Rect(10, 10, 60, 50),
+		image.Rect(-20, 70, 30, 1000), // clipped to the image
+	}
+
+	for _, feather := range []int{0, 5} {
+		r := blurhash.NewRedactor(3, 3)
+		r.Feather = feather
+
+		want := cloneNRGBA(orig)
+		if err := r.Redact(want, regions...); err != nil {
+			t.Fatalf("error redacting: %v", err)
+		}
+		got := drawOnly{cloneNRGBA(orig)}
+		if err := r.Redact(got, regions...); err != nil {
+			t.Fatalf("error redacting: %v", err)
+		}
+
+		b := orig.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if g, w := got.img.NRGBAAt(x, y), want.NRGBAAt(x, y); g != w {
+					t.Fatalf("feather=%d: pixel (%d, %d) = %v, want %v", feather, x, y, g, w)
+				}
+			}
+		}
+	}
+}
+
+func TestRedactInvalid(t *testing.T) {
+	dst := rampImage(16, 16)
+	if err := blurhash.Redact(dst, 0, 3, dst.Bounds()); !errors.Is(err, blurhash.ErrInvalidComponents) {
+		t.Errorf("expected ErrInvalidComponents, got %v", err)
+	}
+	if err := blurhash.RedactMask(dst, 3, 10, image.NewAlpha(dst.Bounds())); !errors.Is(err, blurhash.ErrInvalidComponents) {
+		t.Errorf("expected ErrInvalidComponents, got %v", err)
+	}
+
+	r := blurhash.NewRedactor(3, 3)
+	r.Feather = -1
+	if err := r.Redact(dst, dst.Bounds()); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected ErrInvalidDimensions, got %v", err)
+	}
+}
+
+// drawOnly hides every method of an image beyond draw.Image.
+type drawOnly struct {
+	img *image.NRGBA
+}
+
+func (d drawOnly) ColorModel() color.Model     { return d.img.ColorModel() }
+func (d drawOnly) Bounds() image.Rectangle     { return d.img.Bounds() }
+func (d drawOnly) At(x, y int) color.Color     { return d.img.At(x, y) }
+func (d drawOnly) Set(x, y int, c color.Color) { d.img.Set(x, y, c) }
+
+// renderRegion returns the decoded blurhash of region of img, as Redact
+// renders it.
+func renderRegion(t *testing.T, img *image.NRGBA, region image.Rectangle) *image.NRGBA {
+	t.Helper()
+	hash, err := blurhash.Encode(4, 3, img.SubImage(region))
+	if err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+	render, err := blurhash.Decode(hash, region.Dx(), region.Dy(), 1)
+	if err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	return render.(*image.NRGBA)
+}
+
+func loadNRGBA(t *testing.T, file string) *image.NRGBA {
+	t.Helper()
+	src := blurhash.LoadFixture(t, file)
+	img := image.NewNRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	return img
+}
+
+func cloneNRGBA(src *image.NRGBA) *image.NRGBA {
+	dst := image.NewNRGBA(src.Bounds())
+	copy(dst.Pix, src.Pix)
+	return dst
+}
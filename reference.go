@@ -0,0 +1,102 @@
+package blurhash
+
+import "math"
+
+// This file holds the arithmetic of ProfileReference, which follows the
+// TypeScript reference implementation step for step. Math.pow is not
+// reproduced directly: the values that depend on it are tabulated in
+// reference_lut.go, which is generated by running the reference code.
+
+// referenceSRGBToLinear converts an 8-bit sRGB value to linear as the
+// reference implementation does.
+func referenceSRGBToLinear(val int) float64 {
+	return referenceSRGBToLinearLUT[val]
+}
+
+// referenceLinearToSRGB converts a linear value to an 8-bit sRGB value as
+// the reference implementation does, by finding the number of thresholds
+// at or below val.
+func referenceLinearToSRGB(val float64) int {
+	lo, hi := 0, len(referenceSRGBThresholds)
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if referenceSRGBThresholds[mid] <= val {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
+
+// fillReferenceCosines fills table as fillCosines does, evaluating the
+// angles as the reference implementation does. The reference encoder
+// computes (π·i·x)/n, while its decoder computes (π·x·i)/n, which rounds
+// differently, so decoder selects the latter.
+func fillReferenceCosines(table []float64, components, n int, decoder bool) {
+	for i := 0; i < components; i++ {
+		for x := 0; x < n; x++ {
+			var angle float64
+			if decoder {
+				angle = float64(math.Pi*float64(x)) * float64(i)
+			} else {
+				angle = float64(math.Pi*float64(i)) * float64(x)
+			}
+			table[i*n+x] = fdlibmCos(angle / float64(n))
+		}
+	}
+}
+
+// referenceMultiplyBasisFunction computes a DCT factor as the reference
+// encoder does: column by column, with the normalisation applied to each
+// basis value and the scale applied once at the end.
+func referenceMultiplyBasisFunction(xComp, yComp int, pix []uint8, stride int, layout packedLayout, cosX, cosY []float64) [3]float64 {
+	var r, g, b float64
+	width, height := len(cosX), len(cosY)
+
+	normalisation := 2.0
+	if xComp == 0 && yComp == 0 {
+		normalisation = 1.0
+	}
+
+	for x := 0; x < width; x++ {
+		basisX := float64(normalisation * cosX[x])
+		for y := 0; y < height; y++ {
+			i := y*stride + x*layout.size
+			basis := float64(basisX * cosY[y])
+			r += float64(basis * referenceSRGBToLinear(int(pix[i+layout.r])))
+			g += float64(basis * referenceSRGBToLinear(int(pix[i+layout.g])))
+			b += float64(basis * referenceSRGBToLinear(int(pix[i+layout.b])))
+		}
+	}
+
+	scale := 1 / float64(width*height)
+	return [3]float64{
+		r * scale,
+		g * scale,
+		b * scale,
+	}
+}
+
+// referenceDecodeRow fills linear with the linear values of row y as
+// decodeRow does, evaluating every basis function for every pixel in the
+// order the reference decoder does rather than separably.
+func (d *Decoder) referenceDecodeRow(linear []float64, y, width, height, numX, numY int) {
+	for x := 0; x < width; x++ {
+		var r, g, b float64
+		for j := 0; j < numY; j++ {
+			basisY := d.cosY[j*height+y]
+			for i := 0; i < numX; i++ {
+				basis := d.cosX[i*width+x] * basisY
+				compColor := d.colors[i+j*numX]
+				r += float64(compColor[0] * basis)
+				g += float64(compColor[1] * basis)
+				b += float64(compColor[2] * basis)
+			}
+		}
+
+		linear[x*3] = r
+		linear[x*3+1] = g
+		linear[x*3+2] = b
+	}
+}
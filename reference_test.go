@@ -0,0 +1,125 @@
+package blurhash_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+// referenceCorpus holds hashes and pixels produced by the TypeScript
+// reference implementation. See testdata/reference/reference.js.
+type referenceCorpus struct {
+	Encode []struct {
+		File string
+		X, Y int
+		Hash string
+	}
+	Decode []struct {
+		Hash          string
+		Width, Height int
+		Punch         float64
+		Pixels        []byte
+	}
+}
+
+func loadReferenceCorpus(t testing.TB) referenceCorpus {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.FromSlash("testdata/reference/corpus.json"))
+	if err != nil {
+		t.Fatalf("error reading corpus: %v", err)
+	}
+	var corpus referenceCorpus
+	if err := json.Unmarshal(data, &corpus); err != nil {
+		t.Fatalf("error parsing corpus: %v", err)
+	}
+	return corpus
+}
+
+func TestReferenceEncode(t *testing.T) {
+	corpus := loadReferenceCorpus(t)
+	images := map[string]image.Image{}
+	enc := blurhash.Encoder{Profile: blurhash.ProfileReference}
+
+	for _, test := range corpus.Encode {
+		img, ok := images[test.File]
+		if !ok {
+			img = blurhash.LoadFixture(t, test.File)
+			images[test.File] = img
+		}
+
+		hash, err := enc.Encode(test.X, test.Y, img)
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if hash != test.Hash {
+			t.Errorf("%s %dx%d: hash mismatch: got %q, want %q", test.File, test.X, test.Y, hash, test.Hash)
+		}
+	}
+}
+
+func TestReferenceDecode(t *testing.T) {
+	corpus := loadReferenceCorpus(t)
+	dec := blurhash.Decoder{Profile: blurhash.ProfileReference}
+
+	for _, test := range corpus.Decode {
+		dst := image.NewNRGBA(image.Rect(0, 0, test.Width, test.Height))
+		if err := dec.DecodeDraw(dst, test.Hash, test.Punch); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if !bytes.Equal(dst.Pix, test.Pixels) {
+			var diff int
+			for i := range dst.Pix {
+				if dst.Pix[i] != test.Pixels[i] {
+					diff++
+				}
+			}
+			t.Errorf("%s %dx%d punch %v: %d of %d channels differ from the reference", test.Hash, test.Width, test.Height, test.Punch, diff, len(dst.Pix))
+		}
+	}
+}
+
+// TestReferenceDiffers checks that the corpus exercises the differences
+// between ProfileReference and the default profile.
+func TestReferenceDiffers(t *testing.T) {
+	corpus := loadReferenceCorpus(t)
+
+	var hashes, pixels int
+	for _, test := range corpus.Encode {
+		hash, err := blurhash.Encode(test.X, test.Y, blurhash.LoadFixture(t, test.File))
+		if err != nil {
+			t.Fatalf("encode error: %v", err)
+		}
+		if hash != test.Hash {
+			hashes++
+		}
+	}
+	for _, test := range corpus.Decode {
+		dst := image.NewNRGBA(image.Rect(0, 0, test.Width, test.Height))
+		if err := blurhash.DecodeDraw(dst, test.Hash, test.Punch); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		if !bytes.Equal(dst.Pix, test.Pixels) {
+			pixels++
+		}
+	}
+	if hashes == 0 || pixels == 0 {
+		t.Errorf("expected the default profile to differ from the reference: %d hashes and %d pixel dumps differ", hashes, pixels)
+	}
+}
+
+func BenchmarkReferenceDecode(b *testing.B) {
+	dec := blurhash.Decoder{Profile: blurhash.ProfileReference}
+	dst := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := dec.DecodeDraw(dst, testFixtures[0].hash, 1); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// Budget constrains the hashes considered by Encoder.Search.
+type Budget struct {
+	// MaxLength is the maximum hash length in characters.
+	// Zero allows every component grid, up to 9x9 (166 characters).
+	MaxLength int
+
+	// MaxError, if positive, selects the shortest hash whose Error does
+	// not exceed it, instead of the hash with the lowest Error. If no
+	// candidate is good enough, the lowest-error candidate is chosen.
+	MaxError float64
+}
+
+// Candidate describes a component grid evaluated by Encoder.Search.
+type Candidate struct {
+	XComponents, YComponents int
+	Hash                     string
+
+	// Error is the mean perceptual distance between the decoded hash
+	// and a downsample of the source image, measured as Euclidean
+	// distance in Oklab scaled by 100. A value around 1 is barely
+	// noticeable; larger values mean a less faithful placeholder.
+	Error float64
+}
+
+// SearchResult is the outcome of Encoder.Search.
+type SearchResult struct {
+	// Candidate is the chosen hash.
+	Candidate
+
+	// Candidates holds every evaluated candidate, ordered by hash
+	// length and then by YComponents, for diagnostics.
+	Candidates []Candidate
+}
+
+// Search evaluates every component grid allowed by b and returns the
+// best-looking hash, along with the full table of candidates.
+//
+// DCT factors are computed once for the largest grid and shared between
+// candidates, each of which is quantised using the encoder's Optimise
+// mode. Note that a rendered Optimise mode is applied to every candidate,
+// which makes searching considerably slower.
+func (e *Encoder) Search(img image.Image, b Budget) (SearchResult, error) {
+	maxArea := maxComponents * maxComponents
+	if b.MaxLength > 0 {
+		maxArea = (b.MaxLength - 4) / 2
+	}
+	if maxArea < 1 {
+		return SearchResult{}, fmt.Errorf("%w: no component grid fits in %d characters", ErrInvalidComponents, b.MaxLength)
+	}
+
+	var grids [][2]int
+	maxX, maxY := 1, 1
+	for y := minComponents; y <= maxComponents; y++ {
+		for x := minComponents; x <= maxComponents; x++ {
+			if x*y > maxArea {
+				continue
+			}
+			grids = append(grids, [2]int{x, y})
+			if x > maxX {
+				maxX = x
+			}
+			if y > maxY {
+				maxY = y
+			}
+		}
+	}
+	sort.SliceStable(grids, func(i, j int) bool {
+		return grids[i][0]*grids[i][1] < grids[j][0]*grids[j][1]
+	})
+
+	if err := e.computeFactors(maxX, maxY, img, true); err != nil {
+		return SearchResult{}, err
+	}
+	all := append([][3]float64(nil), e.factors...)
+
+	var result SearchResult
+	result.Candidates = make([]Candidate, 0, len(grids))
+	for _, grid := range grids {
+		x, y := grid[0], grid[1]
+		factors := growTo(e.factors, x*y)
+		for j := 0; j < y; j++ {
+			copy(factors[j*x:(j+1)*x], all[j*maxX:j*maxX+x])
+		}
+
+		q := e.quantiseOptimised(x, y, factors)
+		hash, err := e.writeQuantised(q)
+		if err != nil {
+			return SearchResult{}, err
+		}
+
+		e.colors = growTo(e.colors, x*y)
+		q.colors(e.colors)
+		e.renderGrid(x, y, e.colors)
+		result.Candidates = append(result.Candidates, Candidate{
+			XComponents: x,
+			YComponents: y,
+			Hash:        hash,
+			Error:       e.meanDistance(spaceOklab) * 100,
+		})
+	}
+
+	best := -1
+	if b.MaxError > 0 {
+		for i, c := range result.Candidates {
+			if c.Error > b.MaxError {
+				continue
+			}
+			if best < 0 || len(c.Hash) < len(result.Candidates[best].Hash) ||
+				(len(c.Hash) == len(result.Candidates[best].Hash) && c.Error < result.Candidates[best].Error) {
+				best = i
+			}
+		}
+	}
+	if best < 0 {
+		for i, c := range result.Candidates {
+			if best < 0 || c.Error < result.Candidates[best].Error {
+				best = i
+			}
+		}
+	}
+	result.Candidate = result.Candidates[best]
+
+	return result, nil
+}
+
+// meanDistance returns the mean Euclidean distance, in the given colour
+// space, between e.render and e.target.
+func (e *Encoder) meanDistance(space colorSpace) float64 {
+	e.useSpace(space)
+	var sum float64
+	for i, c := range e.render {
+		c = space.convert(c)
+		t := e.target[i]
+		sum += math.Sqrt((c[0]-t[0])*(c[0]-t[0]) + (c[1]-t[1])*(c[1]-t[1]) + (c[2]-t[2])*(c[2]-t[2]))
+	}
+	return sum / float64(len(e.render))
+}
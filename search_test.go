@@ -0,0 +1,119 @@
+package blurhash_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestEncoderSearch(t *testing.T) {
+	for _, test := range testFixtures {
+		if test.file == "" {
+			continue
+		}
+
+		t.Run(test.hash, func(t *testing.T) {
+			img := blurhash.LoadFixture(t, test.file)
+			maxLength := len(test.hash)
+
+			var enc blurhash.Encoder
+			result, err := enc.Search(img, blurhash.Budget{MaxLength: maxLength})
+			if err != nil {
+				t.Fatalf("search error: %v", err)
+			}
+			if len(result.Candidates) == 0 {
+				t.Fatal("search should report candidates")
+			}
+
+			found := false
+			for i, c := range result.Candidates {
+				if len(c.Hash) > maxLength {
+					t.Errorf("candidate %q exceeds the length budget", c.Hash)
+				}
+				if i > 0 && len(c.Hash) < len(result.Candidates[i-1].Hash) {
+					t.Errorf("candidates should be ordered by length")
+				}
+				if c.Error < result.Error {
+					t.Errorf("candidate %q has lower error %v than the winner %v", c.Hash, c.Error, result.Error)
+				}
+				// Factors are shared between candidates, so each must
+				// match a standalone encode with the same components.
+				if c.XComponents == test.xComp && c.YComponents == test.yComp {
+					found = true
+					if c.Hash != test.hash {
+						t.Errorf("candidate hash mismatch: got %q, want %q", c.Hash, test.hash)
+					}
+				}
+			}
+			if !found {
+				t.Errorf("candidates should include the %dx%d grid", test.xComp, test.yComp)
+			}
+			t.Logf("best %dx%d %q with error %.2f", result.XComponents, result.YComponents, result.Hash, result.Error)
+		})
+	}
+}
+
+func TestEncoderSearchMaxError(t *testing.T) {
+	img := blurhash.LoadFixture(t, "fixtures/test.png")
+
+	var enc blurhash.Encoder
+	all, err := enc.Search(img, blurhash.Budget{})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if len(all.Candidates) != 81 {
+		t.Errorf("an unlimited search should evaluate all 81 grids, got %d", len(all.Candidates))
+	}
+
+	// Ask for a quality that only some of the candidates achieve.
+	worst := all.Candidates[0].Error
+	maxError := (worst + all.Error) / 2
+	result, err := enc.Search(img, blurhash.Budget{MaxError: maxError})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if result.Error > maxError {
+		t.Errorf("result error %v exceeds MaxError %v", result.Error, maxError)
+	}
+	for _, c := range result.Candidates {
+		if c.Error <= maxError && len(c.Hash) < len(result.Hash) {
+			t.Errorf("candidate %q is shorter than %q and meets MaxError", c.Hash, result.Hash)
+		}
+	}
+
+	// An unachievable quality falls back to the lowest-error candidate.
+	result, err = enc.Search(img, blurhash.Budget{MaxError: 1e-9})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if result.Hash != all.Hash {
+		t.Errorf("unachievable MaxError should pick the lowest-error hash: got %q, want %q", result.Hash, all.Hash)
+	}
+}
+
+func TestEncoderSearchInvalidBudget(t *testing.T) {
+	img := blurhash.LoadFixture(t, "fixtures/test.png")
+
+	var enc blurhash.Encoder
+	if _, err := enc.Search(img, blurhash.Budget{MaxLength: 5}); !errors.Is(err, blurhash.ErrInvalidComponents) {
+		t.Errorf("expected ErrInvalidComponents, got %v", err)
+	}
+
+	result, err := enc.Search(img, blurhash.Budget{MaxLength: 6})
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+	if len(result.Candidates) != 1 || result.XComponents != 1 || result.YComponents != 1 {
+		t.Errorf("a 6 character budget should only allow a 1x1 grid, got %+v", result.Candidates)
+	}
+}
+
+func BenchmarkEncoderSearch(b *testing.B) {
+	img := blurhash.LoadFixture(b, "fixtures/test.png")
+	var enc blurhash.Encoder
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = enc.Search(img, blurhash.Budget{MaxLength: 40})
+	}
+}
@@ -0,0 +1,85 @@
+package blurhash
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/bbrks/go-blurhash/base83"
+)
+
+// decodeRowDirect fills row by evaluating every basis function for every
+// pixel, as decoding did before it was made separable.
+func (d *Decoder) decodeRowDirect(row []uint8, y, width, height, numX, numY int) {
+	for x := 0; x < width; x++ {
+		var r, g, b float64
+		for j := 0; j < numY; j++ {
+			basisY := d.cosY[j*height+y]
+			for i := 0; i < numX; i++ {
+				basis := d.cosX[i*width+x] * basisY
+				compColor := d.colors[i+j*numX]
+				r += float64(compColor[0] * basis)
+				g += float64(compColor[1] * basis)
+				b += float64(compColor[2] * basis)
+			}
+		}
+
+		row[x*3] = uint8(d.Profile.linearToSRGB(r))
+		row[x*3+1] = uint8(d.Profile.linearToSRGB(g))
+		row[x*3+2] = uint8(d.Profile.linearToSRGB(b))
+	}
+}
+
+// randomHash returns a valid hash with random components and values.
+func randomHash(t *testing.T, rng *rand.Rand) string {
+	t.Helper()
+	numX, numY := rng.Intn(maxComponents)+1, rng.Intn(maxComponents)+1
+	values := []struct{ v, n int }{
+		{numX - 1 + (numY-1)*9, 1},
+		{rng.Intn(83), 1},
+		{rng.Intn(1 << 24), 4},
+	}
+	for i := 1; i < numX*numY; i++ {
+		values = append(values, struct{ v, n int }{rng.Intn(19 * 19 * 19), 2})
+	}
+
+	var hash string
+	for _, v := range values {
+		s, err := base83.Encode(v.v, v.n)
+		if err != nil {
+			t.Fatalf("error encoding: %v", err)
+		}
+		hash += s
+	}
+	return hash
+}
+
+func TestDecodeRowSeparable(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, p := range []Profile{ProfileV12, ProfileLegacy} {
+		for _, deterministic := range []bool{false, true} {
+			d := Decoder{Profile: p, Deterministic: deterministic}
+			for n := 0; n < 200; n++ {
+				hash := randomHash(t, rng)
+				width, height := rng.Intn(64)+1, rng.Intn(64)+1
+				numX, numY, err := d.prepare(hash, width, height, 1)
+				if err != nil {
+					t.Fatalf("error preparing %q: %v", hash, err)
+				}
+
+				var w rowWorker
+				w.reset(width, numX, DitherNone, false, false)
+				want := make([]uint8, width*3)
+				for y := 0; y < height; y++ {
+					d.decodeRow(&w, y, width, height, numX, numY)
+					d.decodeRowDirect(want, y, width, height, numX, numY)
+					for i := range want {
+						if w.row[i] != want[i] {
+							t.Fatalf("%v deterministic=%v: %q at %dx%d: row %d byte %d = %d, want %d",
+								p, deterministic, hash, width, height, y, i, w.row[i], want[i])
+						}
+					}
+				}
+			}
+		}
+	}
+}
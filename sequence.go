@@ -0,0 +1,183 @@
+package blurhash
+
+import (
+	"errors"
+	"image"
+	"io"
+	"math"
+)
+
+// Frame is the result of encoding a single frame of a sequence.
+type Frame struct {
+	// Hash is the blurhash of the frame, after any temporal smoothing.
+	Hash string
+	// Distance is the Euclidean distance between the unsmoothed DCT
+	// coefficients of this frame and those of the previous frame.
+	// It is zero for the first frame.
+	Distance float64
+	// SceneChange reports whether this frame starts a new scene.
+	// The first frame always starts a scene.
+	SceneChange bool
+}
+
+// SequenceEncoder produces blurhashes for an ordered sequence of frames,
+// such as decoded video or a live camera preview.
+//
+// Coefficients can be smoothed over time to reduce flicker between
+// consecutive hashes, and large jumps in coefficients are reported as
+// scene changes, which also reset smoothing.
+//
+// A SequenceEncoder is safe for sequential use but not for concurrent use.
+type SequenceEncoder struct {
+	// Smoothing is the weight given to the previous frame's smoothed
+	// coefficients when blending in a new frame, in the range [0, 1).
+	// Zero disables temporal smoothing.
+	Smoothing float64
+
+	// SceneThreshold is the coefficient Distance above which a frame is
+	// treated as the start of a new scene. Coefficients are linear RGB
+	// values, so useful thresholds are typically in the range 0.05-0.5.
+	// Zero disables scene-change detection.
+	SceneThreshold float64
+
+	xComponents, yComponents int
+
+	enc      Encoder
+	raw      [][3]float64 // unsmoothed factors of the previous frame
+	smoothed [][3]float64 // smoothed factors of the previous frame
+	frames   []Frame
+}
+
+// NewSequenceEncoder creates a SequenceEncoder producing hashes with the
+// given number of components.
+func NewSequenceEncoder(xComponents, yComponents int) *SequenceEncoder {
+	return &SequenceEncoder{
+		xComponents: xComponents,
+		yComponents: yComponents,
+	}
+}
+
+// Add encodes the next frame of the sequence.
+func (s *SequenceEncoder) Add(img image.Image) (Frame, error) {
+	if err := s.enc.computeFactors(s.xComponents, s.yComponents, img, false); err != nil {
+		return Frame{}, err
+	}
+	factors := s.enc.factors
+
+	var frame Frame
+	if len(s.frames) == 0 {
+		frame.SceneChange = true
+	} else {
+		frame.Distance = factorDistance(factors, s.raw)
+		frame.SceneChange = s.SceneThreshold > 0 && frame.Distance > s.SceneThreshold
+	}
+
+	s.raw = growTo(s.raw, len(factors))
+	copy(s.raw, factors)
+
+	if frame.SceneChange || s.Smoothing <= 0 {
+		s.smoothed = growTo(s.smoothed, len(factors))
+		copy(s.smoothed, factors)
+	} else {
+		alpha := math.Min(s.Smoothing, 1)
+		for i, f := range factors {
+			for c := 0; c < 3; c++ {
+				s.smoothed[i][c] = alpha*s.smoothed[i][c] + (1-alpha)*f[c]
+			}
+		}
+	}
+
+	hash, err := s.enc.writeHash(s.xComponents, s.yComponents, s.smoothed)
+	if err != nil {
+		return Frame{}, err
+	}
+	frame.Hash = hash
+
+	s.frames = append(s.frames, frame)
+	return frame, nil
+}
+
+// AddFrames encodes every frame from r until it returns io.EOF.
+func (s *SequenceEncoder) AddFrames(r FrameReader) error {
+	for {
+		img, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := s.Add(img); err != nil {
+			return err
+		}
+	}
+}
+
+// Frames returns the frames encoded so far, in order.
+func (s *SequenceEncoder) Frames() []Frame {
+	return s.frames
+}
+
+// Keyframe returns the index and value of the frame that best represents
+// the sequence: the frame of the longest scene whose coefficients are
+// closest to that scene's mean. It returns false if no frames were added.
+func (s *SequenceEncoder) Keyframe() (int, Frame, bool) {
+	if len(s.frames) == 0 {
+		return 0, Frame{}, false
+	}
+
+	// Find the longest scene, preferring the earliest on ties.
+	bestStart, bestLen := 0, 0
+	for start := 0; start < len(s.frames); {
+		end := start + 1
+		for end < len(s.frames) && !s.frames[end].SceneChange {
+			end++
+		}
+		if end-start > bestLen {
+			bestStart, bestLen = start, end-start
+		}
+		start = end
+	}
+
+	n := s.xComponents * s.yComponents
+	colors := make([][3]float64, bestLen*n)
+	mean := make([][3]float64, n)
+	for k := 0; k < bestLen; k++ {
+		frameColors := colors[k*n : (k+1)*n]
+		if err := decodeColors(frameColors, s.frames[bestStart+k].Hash, 1, ProfileV12); err != nil {
+			return 0, Frame{}, false
+		}
+		for i, c := range frameColors {
+			for ch := 0; ch < 3; ch++ {
+				mean[i][ch] += c[ch] / float64(bestLen)
+			}
+		}
+	}
+
+	best, bestDist := bestStart, math.Inf(1)
+	for k := 0; k < bestLen; k++ {
+		if dist := factorDistance(colors[k*n:(k+1)*n], mean); dist < bestDist {
+			best, bestDist = bestStart+k, dist
+		}
+	}
+	return best, s.frames[best], true
+}
+
+// Reset discards all frames, so the encoder can be reused for a new sequence.
+func (s *SequenceEncoder) Reset() {
+	s.frames = s.frames[:0]
+	s.raw = s.raw[:0]
+	s.smoothed = s.smoothed[:0]
+}
+
+// factorDistance returns the Euclidean distance between two sets of factors.
+func factorDistance(a, b [][3]float64) float64 {
+	var sum float64
+	for i := range a {
+		for c := 0; c < 3; c++ {
+			d := a[i][c] - b[i][c]
+			sum += d * d
+		}
+	}
+	return math.Sqrt(sum)
+}
@@ -0,0 +1,313 @@
+package blurhash_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func solidImage(c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func gradientImage(c color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{c.R, uint8(x * 16), uint8(y * 16), 255})
+		}
+	}
+	return img
+}
+
+func TestSequenceEncoderStatic(t *testing.T) {
+	img := gradientImage(color.NRGBA{R: 200})
+	want, err := blurhash.Encode(4, 3, img)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	seq := blurhash.NewSequenceEncoder(4, 3)
+	seq.Smoothing = 0.8
+	seq.SceneThreshold = 0.1
+	for i := 0; i < 5; i++ {
+		frame, err := seq.Add(img)
+		if err != nil {
+			t.Fatalf("frame %d: add error: %v", i, err)
+		}
+		if frame.Hash != want {
+			t.Errorf("frame %d: hash mismatch: got %q, want %q", i, frame.Hash, want)
+		}
+		if frame.Distance != 0 {
+			t.Errorf("frame %d: distance should be zero for identical frames, got %v", i, frame.Distance)
+		}
+		if frame.SceneChange != (i == 0) {
+			t.Errorf("frame %d: unexpected scene change %v", i, frame.SceneChange)
+		}
+	}
+}
+
+func TestSequenceEncoderSceneChange(t *testing.T) {
+	red := gradientImage(color.NRGBA{R: 255})
+	blue := solidImage(color.NRGBA{B: 255, A: 255})
+	wantBlue, err := blurhash.Encode(4, 3, blue)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	t.Run("detected", func(t *testing.T) {
+		seq := blurhash.NewSequenceEncoder(4, 3)
+		seq.Smoothing = 0.5
+		seq.SceneThreshold = 0.1
+		for _, img := range []image.Image{red, red, blue} {
+			if _, err := seq.Add(img); err != nil {
+				t.Fatalf("add error: %v", err)
+			}
+		}
+
+		frames := seq.Frames()
+		if !frames[2].SceneChange {
+			t.Errorf("switching images should be a scene change, distance %v", frames[2].Distance)
+		}
+		if frames[2].Hash != wantBlue {
+			t.Errorf("scene change should reset smoothing: got %q, want %q", frames[2].Hash, wantBlue)
+		}
+	})
+
+	t.Run("smoothed", func(t *testing.T) {
+		seq := blurhash.NewSequenceEncoder(4, 3)
+		seq.Smoothing = 0.5
+		for _, img := range []image.Image{red, red, blue} {
+			if _, err := seq.Add(img); err != nil {
+				t.Fatalf("add error: %v", err)
+			}
+		}
+
+		frames := seq.Frames()
+		if frames[2].SceneChange {
+			t.Error("scene detection should be disabled without a threshold")
+		}
+		if frames[2].Hash == wantBlue {
+			t.Error("smoothing should blend the previous frame into the hash")
+		}
+	})
+}
+
+func TestSequenceEncoderKeyframe(t *testing.T) {
+	seq := blurhash.NewSequenceEncoder(3, 3)
+	seq.SceneThreshold = 0.1
+
+	if _, _, ok := seq.Keyframe(); ok {
+		t.Error("keyframe of an empty sequence should not be ok")
+	}
+
+	// A short red scene followed by a longer, slowly brightening blue scene.
+	imgs := []image.Image{
+		gradientImage(color.NRGBA{R: 255}),
+		gradientImage(color.NRGBA{R: 255}),
+	}
+	for b := 200; b <= 220; b += 5 {
+		imgs = append(imgs, solidImage(color.NRGBA{B: uint8(b), A: 255}))
+	}
+	for _, img := range imgs {
+		if _, err := seq.Add(img); err != nil {
+			t.Fatalf("add error: %v", err)
+		}
+	}
+
+	idx, frame, ok := seq.Keyframe()
+	if !ok {
+		t.Fatal("keyframe should be ok")
+	}
+	// The middle of the blue scene is closest to its mean.
+	if idx != 4 {
+		t.Errorf("keyframe index mismatch: got %d, want 4", idx)
+	}
+	if frame != seq.Frames()[idx] {
+		t.Errorf("keyframe should match frame %d", idx)
+	}
+
+	seq.Reset()
+	if len(seq.Frames()) != 0 {
+		t.Error("reset should discard frames")
+	}
+}
+
+func TestSequenceEncoderInvalidComponents(t *testing.T) {
+	seq := blurhash.NewSequenceEncoder(0, 3)
+	_, err := seq.Add(solidImage(color.NRGBA{A: 255}))
+	if !errors.Is(err, blurhash.ErrInvalidComponents) {
+		t.Errorf("expected ErrInvalidComponents, got %v", err)
+	}
+}
+
+func TestY4MReader(t *testing.T) {
+	const w, h = 6, 4
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for i := range ycbcr.Y {
+		ycbcr.Y[i] = uint8(i * 9)
+	}
+	for i := range ycbcr.Cb {
+		ycbcr.Cb[i] = uint8(100 + i*10)
+		ycbcr.Cr[i] = uint8(200 - i*10)
+	}
+
+	var stream bytes.Buffer
+	fmt.Fprintf(&stream, "YUV4MPEG2 W%d H%d F25:1 Ip A1:1 C420jpeg XCOLORRANGE=FULL\n", w, h)
+	for i := 0; i < 2; i++ {
+		stream.WriteString("FRAME\n")
+		stream.Write(ycbcr.Y)
+		stream.Write(ycbcr.Cb)
+		stream.Write(ycbcr.Cr)
+	}
+
+	want, err := blurhash.Encode(3, 2, ycbcr)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	r, err := blurhash.NewY4MReader(&stream)
+	if err != nil {
+		t.Fatalf("error reading header: %v", err)
+	}
+	seq := blurhash.NewSequenceEncoder(3, 2)
+	if err := seq.AddFrames(r); err != nil {
+		t.Fatalf("error encoding frames: %v", err)
+	}
+
+	frames := seq.Frames()
+	if len(frames) != 2 {
+		t.Fatalf("frame count mismatch: got %d, want 2", len(frames))
+	}
+	for i, frame := range frames {
+		if frame.Hash != want {
+			t.Errorf("frame %d: hash mismatch: got %q, want %q", i, frame.Hash, want)
+		}
+	}
+}
+
+func TestY4MReaderLimitedRange(t *testing.T) {
+	var stream bytes.Buffer
+	stream.WriteString("YUV4MPEG2 W2 H1 Cmono\nFRAME\n")
+	stream.Write([]byte{16, 235})
+
+	r, err := blurhash.NewY4MReader(&stream)
+	if err != nil {
+		t.Fatalf("error reading header: %v", err)
+	}
+	img, err := r.Next()
+	if err != nil {
+		t.Fatalf("error reading frame: %v", err)
+	}
+	gray := img.(*image.Gray)
+	if gray.Pix[0] != 0 || gray.Pix[1] != 255 {
+		t.Errorf("limited range should expand to full range: got %v", gray.Pix)
+	}
+}
+
+func TestY4MReaderInvalid(t *testing.T) {
+	for _, header := range []string{
+		"",
+		"NOTY4M W2 H2\n",
+		"YUV4MPEG2 W0 H2\n",
+		"YUV4MPEG2 W1099511627776 H1099511627776\n",
+		"YUV4MPEG2 W100000 H100000 Cmono\n",
+		"YUV4MPEG2 W2 H2 C420p10\n",
+	} {
+		_, err := blurhash.NewY4MReader(strings.NewReader(header))
+		if !errors.Is(err, blurhash.ErrInvalidStream) {
+			t.Errorf("header %q: expected ErrInvalidStream, got %v", header, err)
+		}
+	}
+
+	r, err := blurhash.NewY4MReader(strings.NewReader("YUV4MPEG2 W2 H2 C444\nFRAME\n\x00\x00"))
+	if err != nil {
+		t.Fatalf("error reading header: %v", err)
+	}
+	if _, err := r.Next(); !errors.Is(err, blurhash.ErrInvalidStream) {
+		t.Errorf("truncated frame: expected ErrInvalidStream, got %v", err)
+	}
+}
+
+func TestPPMReader(t *testing.T) {
+	img := gradientImage(color.NRGBA{R: 128})
+	want, err := blurhash.Encode(4, 3, img)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	var stream bytes.Buffer
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(&stream, "P6\n# frame %d\n16 16\n255\n", i)
+		for p := 0; p < len(img.Pix); p += 4 {
+			stream.Write(img.Pix[p : p+3])
+		}
+	}
+
+	seq := blurhash.NewSequenceEncoder(4, 3)
+	if err := seq.AddFrames(blurhash.NewPPMReader(&stream)); err != nil {
+		t.Fatalf("error encoding frames: %v", err)
+	}
+	frames := seq.Frames()
+	if len(frames) != 3 {
+		t.Fatalf("frame count mismatch: got %d, want 3", len(frames))
+	}
+	for i, frame := range frames {
+		if frame.Hash != want {
+			t.Errorf("frame %d: hash mismatch: got %q, want %q", i, frame.Hash, want)
+		}
+	}
+}
+
+func TestPPMReaderFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		stream string
+		want   color.Color
+	}{
+		{"pgm", "P5 1 1 255\n\x80", color.Gray{0x80}},
+		{"pgm 16-bit", "P5 1 1 65535\n\x12\x34", color.Gray16{0x1234}},
+		{"ppm 16-bit", "P6 1 1 65535\n\x12\x34\x56\x78\x9a\xbc", color.NRGBA64{0x1234, 0x5678, 0x9abc, 0xffff}},
+		{"ppm maxval", "P6 1 1 15\n\x0f\x00\x0f", color.NRGBA{255, 0, 255, 255}},
+		{"ppm above maxval", "P6 1 1 15\n\xff\x10\x0f", color.NRGBA{255, 255, 255, 255}},
+		{"pgm 16-bit above maxval", "P5 1 1 1000\n\xff\xff", color.Gray16{0xffff}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := blurhash.NewPPMReader(strings.NewReader(tt.stream)).Next()
+			if err != nil {
+				t.Fatalf("error reading frame: %v", err)
+			}
+			if got := img.At(0, 0); got != tt.want {
+				t.Errorf("pixel mismatch: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPPMReaderInvalid(t *testing.T) {
+	for _, stream := range []string{
+		"P3 1 1 255\n",
+		"P6 x 1 255\n",
+		"P6 1 1 0\n",
+		"P6 16777216 16777216 255\n",
+		"P5 100000 100000 65535\n",
+		"P6 1 1 255\n\x00",
+	} {
+		_, err := blurhash.NewPPMReader(strings.NewReader(stream)).Next()
+		if !errors.Is(err, blurhash.ErrInvalidStream) {
+			t.Errorf("stream %q: expected ErrInvalidStream, got %v", stream, err)
+		}
+	}
+}
@@ -35,4 +35,28 @@ func main() {
 	}
 
 	fmt.Fprintln(f, "}")
+	fmt.Fprintln(f)
+
+	// The table is generated rather than computed at init, so that it
+	// does not depend on the math.Pow or fused multiply-adds of a GOARCH.
+	const size = 4096
+	fmt.Fprintln(f, "// linearToSRGBLUT maps linear values [0,1] to sRGB [0,255].")
+	fmt.Fprintln(f, "// Index i corresponds to linear value i/(linearToSRGBLUTSize-1).")
+	fmt.Fprintf(f, "var linearToSRGBLUT = [linearToSRGBLUTSize]uint8{")
+	for i := 0; i < size; i++ {
+		v := float64(i) / float64(size-1)
+		var srgb float64
+		if v <= 0.0031308 {
+			srgb = float64(v * 12.92)
+		} else {
+			srgb = float64(1.055*math.Pow(v, 1/2.4)) - 0.055
+		}
+		if i%16 == 0 {
+			fmt.Fprint(f, "\n\t")
+		} else {
+			fmt.Fprint(f, " ")
+		}
+		fmt.Fprintf(f, "%d,", uint8(float64(srgb*255)+0.5))
+	}
+	fmt.Fprintln(f, "\n}")
 }
@@ -0,0 +1,352 @@
+package blurhash
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+)
+
+// maxFramePixels bounds the size of the frames a stream may declare, so
+// that a malformed header cannot make a reader allocate without limit.
+const maxFramePixels = 1 << 26
+
+// checkFrameSize returns an error if a frame of the given size is empty
+// or larger than maxFramePixels.
+func checkFrameSize(width, height int) error {
+	if width <= 0 || height <= 0 || width > maxFramePixels/height {
+		return fmt.Errorf("%w: had width=%d, height=%d", ErrInvalidStream, width, height)
+	}
+	return nil
+}
+
+// FrameReader is implemented by sources of sequential frames.
+//
+// Next returns the next frame, or io.EOF once the source is exhausted.
+// The returned image may be reused by subsequent calls to Next.
+type FrameReader interface {
+	Next() (image.Image, error)
+}
+
+// Y4MReader reads frames from a YUV4MPEG2 (.y4m) stream, as produced by
+// e.g. `ffmpeg -f yuv4mpegpipe`.
+//
+// 8-bit 4:2:0, 4:2:2, 4:4:4 and mono streams are supported. Limited range
+// samples (the default for the format) are expanded to full range unless
+// the stream header declares XCOLORRANGE=FULL. Streams whose frames have
+// more than 1<<26 pixels are rejected.
+type Y4MReader struct {
+	r             *bufio.Reader
+	width, height int
+	ratio         image.YCbCrSubsampleRatio
+	mono          bool
+	fullRange     bool
+
+	ycbcr *image.YCbCr
+	gray  *image.Gray
+}
+
+// NewY4MReader parses the stream header from r and returns a reader for its frames.
+func NewY4MReader(r io.Reader) (*Y4MReader, error) {
+	y := &Y4MReader{
+		r:     bufio.NewReader(r),
+		ratio: image.YCbCrSubsampleRatio420,
+	}
+
+	line, err := y.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading y4m header: %v", ErrInvalidStream, err)
+	}
+	fields := bytes.Fields([]byte(line))
+	if len(fields) == 0 || string(fields[0]) != "YUV4MPEG2" {
+		return nil, fmt.Errorf("%w: missing YUV4MPEG2 signature", ErrInvalidStream)
+	}
+
+	for _, f := range fields[1:] {
+		tag, val := f[0], string(f[1:])
+		switch tag {
+		case 'W':
+			y.width, err = strconv.Atoi(val)
+		case 'H':
+			y.height, err = strconv.Atoi(val)
+		case 'C':
+			err = y.parseColorspace(val)
+		case 'X':
+			if val == "COLORRANGE=FULL" {
+				y.fullRange = true
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid y4m header field %q: %v", ErrInvalidStream, f, err)
+		}
+	}
+	if err := checkFrameSize(y.width, y.height); err != nil {
+		return nil, err
+	}
+
+	rect := image.Rect(0, 0, y.width, y.height)
+	if y.mono {
+		y.gray = image.NewGray(rect)
+	} else {
+		y.ycbcr = image.NewYCbCr(rect, y.ratio)
+	}
+	return y, nil
+}
+
+func (y *Y4MReader) parseColorspace(val string) error {
+	switch val {
+	case "420", "420jpeg", "420paldv", "420mpeg2":
+		y.ratio = image.YCbCrSubsampleRatio420
+	case "422":
+		y.ratio = image.YCbCrSubsampleRatio422
+	case "444":
+		y.ratio = image.YCbCrSubsampleRatio444
+	case "mono":
+		y.mono = true
+	default:
+		return fmt.Errorf("unsupported colourspace %q", val)
+	}
+	return nil
+}
+
+// Next reads the next frame from the stream.
+// The returned image is reused by subsequent calls to Next.
+func (y *Y4MReader) Next() (image.Image, error) {
+	line, err := y.r.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading frame header: %v", ErrInvalidStream, err)
+	}
+	if len(line) < 5 || line[:5] != "FRAME" {
+		return nil, fmt.Errorf("%w: missing FRAME marker", ErrInvalidStream)
+	}
+
+	if y.mono {
+		if err := y.readPlane(y.gray.Pix, lumaRange); err != nil {
+			return nil, err
+		}
+		return y.gray, nil
+	}
+
+	for _, plane := range []struct {
+		pix []uint8
+		lut *[256]uint8
+	}{
+		{y.ycbcr.Y, &lumaRange},
+		{y.ycbcr.Cb, &chromaRange},
+		{y.ycbcr.Cr, &chromaRange},
+	} {
+		if err := y.readPlane(plane.pix, *plane.lut); err != nil {
+			return nil, err
+		}
+	}
+	return y.ycbcr, nil
+}
+
+func (y *Y4MReader) readPlane(pix []uint8, lut [256]uint8) error {
+	if _, err := io.ReadFull(y.r, pix); err != nil {
+		return fmt.Errorf("%w: reading frame data: %v", ErrInvalidStream, err)
+	}
+	if !y.fullRange {
+		for i, v := range pix {
+			pix[i] = lut[v]
+		}
+	}
+	return nil
+}
+
+// lumaRange and chromaRange expand limited range (16-235 and 16-240)
+// samples to the full 0-255 range expected by image.YCbCr.
+var lumaRange, chromaRange [256]uint8
+
+func init() {
+	for i := 0; i < 256; i++ {
+		lumaRange[i] = clampUint8((float64(i)-16)*255/219 + 0.5)
+		chromaRange[i] = clampUint8((float64(i)-128)*255/224 + 128.5)
+	}
+}
+
+func clampUint8(v float64) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// PPMReader reads frames from a stream of concatenated binary PPM (P6) or
+// PGM (P5) images, as produced by e.g. `ffmpeg -f image2pipe -c:v ppm`.
+// Frames with more than 1<<26 pixels are rejected, and samples above the
+// maxval of their frame are clamped to it.
+type PPMReader struct {
+	r *bufio.Reader
+
+	nrgba   *image.NRGBA
+	nrgba64 *image.NRGBA64
+	gray    *image.Gray
+	gray16  *image.Gray16
+}
+
+// NewPPMReader returns a reader for the PPM frames in r.
+func NewPPMReader(r io.Reader) *PPMReader {
+	return &PPMReader{r: bufio.NewReader(r)}
+}
+
+// Next reads the next frame from the stream.
+// The returned image is reused by subsequent calls to Next.
+func (p *PPMReader) Next() (image.Image, error) {
+	magic := make([]byte, 2)
+	if _, err := io.ReadFull(p.r, magic); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: reading ppm header: %v", ErrInvalidStream, err)
+	}
+	if magic[0] != 'P' || (magic[1] != '5' && magic[1] != '6') {
+		return nil, fmt.Errorf("%w: unsupported ppm magic %q", ErrInvalidStream, magic)
+	}
+	gray := magic[1] == '5'
+
+	var header [3]int // width, height, maxval
+	for i := range header {
+		v, err := p.readHeaderInt()
+		if err != nil {
+			return nil, err
+		}
+		header[i] = v
+	}
+	width, height, maxval := header[0], header[1], header[2]
+	if err := checkFrameSize(width, height); err != nil {
+		return nil, err
+	}
+	if maxval <= 0 || maxval > 65535 {
+		return nil, fmt.Errorf("%w: had maxval=%d", ErrInvalidStream, maxval)
+	}
+	// Exactly one whitespace byte separates the header from the raster.
+	if _, err := p.r.ReadByte(); err != nil {
+		return nil, fmt.Errorf("%w: reading ppm header: %v", ErrInvalidStream, err)
+	}
+
+	channels := 3
+	if gray {
+		channels = 1
+	}
+	bytesPerSample := 1
+	if maxval > 255 {
+		bytesPerSample = 2
+	}
+	raster := make([]byte, width*height*channels*bytesPerSample)
+	if _, err := io.ReadFull(p.r, raster); err != nil {
+		return nil, fmt.Errorf("%w: reading ppm raster: %v", ErrInvalidStream, err)
+	}
+
+	rect := image.Rect(0, 0, width, height)
+	switch {
+	case gray && bytesPerSample == 1:
+		if p.gray == nil || p.gray.Rect != rect {
+			p.gray = image.NewGray(rect)
+		}
+		for i, v := range raster {
+			p.gray.Pix[i] = scaleSample8(v, maxval)
+		}
+		return p.gray, nil
+	case gray:
+		if p.gray16 == nil || p.gray16.Rect != rect {
+			p.gray16 = image.NewGray16(rect)
+		}
+		for i := 0; i < len(raster); i += 2 {
+			v := scaleSample(raster[i:i+2], maxval)
+			p.gray16.Pix[i], p.gray16.Pix[i+1] = uint8(v>>8), uint8(v)
+		}
+		return p.gray16, nil
+	case bytesPerSample == 1:
+		if p.nrgba == nil || p.nrgba.Rect != rect {
+			p.nrgba = image.NewNRGBA(rect)
+		}
+		for i, j := 0, 0; i < len(raster); i, j = i+3, j+4 {
+			p.nrgba.Pix[j] = scaleSample8(raster[i], maxval)
+			p.nrgba.Pix[j+1] = scaleSample8(raster[i+1], maxval)
+			p.nrgba.Pix[j+2] = scaleSample8(raster[i+2], maxval)
+			p.nrgba.Pix[j+3] = 255
+		}
+		return p.nrgba, nil
+	default:
+		if p.nrgba64 == nil || p.nrgba64.Rect != rect {
+			p.nrgba64 = image.NewNRGBA64(rect)
+		}
+		for i, j := 0, 0; i < len(raster); i, j = i+6, j+8 {
+			for c := 0; c < 3; c++ {
+				v := scaleSample(raster[i+c*2:i+c*2+2], maxval)
+				p.nrgba64.Pix[j+c*2], p.nrgba64.Pix[j+c*2+1] = uint8(v>>8), uint8(v)
+			}
+			p.nrgba64.Pix[j+6], p.nrgba64.Pix[j+7] = 0xff, 0xff
+		}
+		return p.nrgba64, nil
+	}
+}
+
+// scaleSample8 scales an 8-bit sample with the given maxval to 0-255,
+// clamping samples above maxval.
+func scaleSample8(v byte, maxval int) uint8 {
+	return uint8(minInt(int(v), maxval) * 255 / maxval)
+}
+
+// scaleSample scales a big-endian 16-bit sample with the given maxval to
+// 0-65535, clamping samples above maxval. The product is formed in uint32
+// so it cannot overflow where int is 32 bits.
+func scaleSample(b []byte, maxval int) uint16 {
+	v := minInt(int(b[0])<<8|int(b[1]), maxval)
+	return uint16(uint32(v) * 65535 / uint32(maxval))
+}
+
+// readHeaderInt reads a decimal header field, skipping whitespace and comments.
+func (p *PPMReader) readHeaderInt() (int, error) {
+	var c byte
+	var err error
+	for {
+		c, err = p.r.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("%w: reading ppm header: %v", ErrInvalidStream, err)
+		}
+		if c == '#' {
+			if _, err := p.r.ReadString('\n'); err != nil {
+				return 0, fmt.Errorf("%w: reading ppm header: %v", ErrInvalidStream, err)
+			}
+			continue
+		}
+		if !isSpace(c) {
+			break
+		}
+	}
+
+	v := 0
+	for {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("%w: invalid ppm header character %q", ErrInvalidStream, c)
+		}
+		v = v*10 + int(c-'0')
+		if v > 1<<24 {
+			return 0, fmt.Errorf("%w: ppm header value too large", ErrInvalidStream)
+		}
+		c, err = p.r.ReadByte()
+		if err != nil {
+			return 0, fmt.Errorf("%w: reading ppm header: %v", ErrInvalidStream, err)
+		}
+		if isSpace(c) {
+			// Leave the terminating whitespace for the caller, as the
+			// byte after maxval separates the header from the raster.
+			return v, p.r.UnreadByte()
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
@@ -0,0 +1,377 @@
+package blurhash
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"strings"
+
+	"github.com/bbrks/go-blurhash/base83"
+)
+
+const (
+	// maxTileSide is the largest number of columns or rows in a
+	// TileGrid, the most its compact form can hold.
+	maxTileSide = 83 * 83
+
+	// maxTileDimension is the largest source width or height a TileGrid
+	// can record in its compact form.
+	maxTileDimension = 83*83*83*83 - 1
+
+	// tileHeaderLength is the length of the compact form's header.
+	tileHeaderLength = 2 + 2 + 4 + 4
+
+	// tileBlend is the width of the band on either side of a seam over
+	// which DecodeTiles blends neighbouring cells, as a fraction of the
+	// narrower cell.
+	tileBlend = 0.25
+)
+
+// TileGrid is a grid of standard blurhashes, each representing one cell
+// of a larger image. It suits images too large or too elongated for a
+// single hash, such as panoramas and full-page screenshots.
+//
+// A TileGrid marshals to JSON as an object, or can be stored in a compact
+// string form with Compact and ParseTileGrid.
+type TileGrid struct {
+	// Columns and Rows are the number of cells across and down.
+	Columns int `json:"columns"`
+	Rows    int `json:"rows"`
+
+	// Width and Height are the size of the source image, which gives the
+	// aspect ratio of the placeholder.
+	Width  int `json:"width"`
+	Height int `json:"height"`
+
+	// Hashes holds the hash of each cell, in row-major order.
+	Hashes []string `json:"hashes"`
+}
+
+// EncodeTiles splits img into a grid of columns by rows cells of equal
+// size and encodes each cell as a standard blurhash with the given
+// components, using the encoder's options.
+func (e *Encoder) EncodeTiles(columns, rows, xComponents, yComponents int, img image.Image) (TileGrid, error) {
+	if columns < 1 || rows < 1 || columns > maxTileSide || rows > maxTileSide {
+		return TileGrid{}, fmt.Errorf("%w: had columns=%d, rows=%d", ErrInvalidDimensions, columns, rows)
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < columns || height < rows || width > maxTileDimension || height > maxTileDimension {
+		return TileGrid{}, fmt.Errorf("%w: cannot split %dx%d image into %dx%d tiles", ErrInvalidDimensions, width, height, columns, rows)
+	}
+
+	g := TileGrid{
+		Columns: columns,
+		Rows:    rows,
+		Width:   width,
+		Height:  height,
+		Hashes:  make([]string, 0, columns*rows),
+	}
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			cell := image.Rect(
+				bounds.Min.X+col*width/columns, bounds.Min.Y+row*height/rows,
+				bounds.Min.X+(col+1)*width/columns, bounds.Min.Y+(row+1)*height/rows,
+			)
+			hash, err := e.Encode(xComponents, yComponents, subImage(img, cell))
+			if err != nil {
+				return TileGrid{}, err
+			}
+			g.Hashes = append(g.Hashes, hash)
+		}
+	}
+	return g, nil
+}
+
+// EncodeTiles splits img into a grid of columns by rows cells of equal
+// size and encodes each cell as a standard blurhash.
+func EncodeTiles(columns, rows, xComponents, yComponents int, img image.Image) (TileGrid, error) {
+	var e Encoder
+	return e.EncodeTiles(columns, rows, xComponents, yComponents, img)
+}
+
+// Compact returns the compact string form of g: the number of columns and
+// rows less one as two base83 digits each, the source width and height as
+// four digits each, then the hashes concatenated in row-major order.
+// Hashes need no separator, as the length of each follows from its first
+// character.
+func (g TileGrid) Compact() (string, error) {
+	if err := g.validate(); err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, field := range [...]struct{ val, length int }{
+		{g.Columns - 1, 2},
+		{g.Rows - 1, 2},
+		{g.Width, 4},
+		{g.Height, 4},
+	} {
+		str, err := base83.Encode(field.val, field.length)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(str)
+	}
+	for _, hash := range g.Hashes {
+		b.WriteString(hash)
+	}
+	return b.String(), nil
+}
+
+// ParseTileGrid parses the compact string form of a TileGrid, as returned
+// by TileGrid.Compact.
+func ParseTileGrid(s string) (TileGrid, error) {
+	if len(s) < tileHeaderLength {
+		return TileGrid{}, fmt.Errorf("%w: tile grid too short", ErrInvalidHash)
+	}
+
+	var header [4]int
+	for i, field := range [...]string{s[0:2], s[2:4], s[4:8], s[8:12]} {
+		val, err := base83.Decode(field)
+		if err != nil {
+			return TileGrid{}, err
+		}
+		header[i] = val
+	}
+	g := TileGrid{
+		Columns: header[0] + 1,
+		Rows:    header[1] + 1,
+		Width:   header[2],
+		Height:  header[3],
+	}
+
+	// Every hash is at least 6 characters, which bounds the allocation.
+	rest := s[tileHeaderLength:]
+	if g.Columns*g.Rows > len(rest)/6 {
+		return TileGrid{}, fmt.Errorf("%w: tile grid too short for %dx%d tiles", ErrInvalidHash, g.Columns, g.Rows)
+	}
+	g.Hashes = make([]string, 0, g.Columns*g.Rows)
+	for len(rest) > 0 {
+		sizeFlag, err := base83.Decode(rest[:1])
+		if err != nil {
+			return TileGrid{}, err
+		}
+		length := 4 + 2*(sizeFlag%9+1)*(sizeFlag/9+1)
+		if len(rest) < length {
+			return TileGrid{}, fmt.Errorf("%w: truncated tile hash", ErrInvalidHash)
+		}
+		g.Hashes = append(g.Hashes, rest[:length])
+		rest = rest[length:]
+	}
+
+	if err := g.validate(); err != nil {
+		return TileGrid{}, err
+	}
+	return g, nil
+}
+
+// validate returns an error if g cannot be stored or decoded.
+func (g TileGrid) validate() error {
+	if g.Columns < 1 || g.Rows < 1 || g.Columns > maxTileSide || g.Rows > maxTileSide {
+		return fmt.Errorf("%w: invalid tile grid columns=%d, rows=%d", ErrInvalidHash, g.Columns, g.Rows)
+	}
+	if g.Width < g.Columns || g.Height < g.Rows || g.Width > maxTileDimension || g.Height > maxTileDimension {
+		return fmt.Errorf("%w: invalid tile grid size width=%d, height=%d", ErrInvalidHash, g.Width, g.Height)
+	}
+	if len(g.Hashes) != g.Columns*g.Rows {
+		return fmt.Errorf("%w: expected %d tile hashes, got %d", ErrInvalidHash, g.Columns*g.Rows, len(g.Hashes))
+	}
+	for _, hash := range g.Hashes {
+		if _, _, err := Components(hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tileSpan records the cells contributing to a column or row of pixels
+// decoded by DecodeTiles, and their weights.
+type tileSpan struct {
+	// cell[1] is -1 if only cell[0] contributes.
+	cell   [2]int
+	weight [2]float64
+}
+
+// DecodeTiles decodes g into dst, stretching the grid over the bounds of
+// dst, which must be at least one pixel per cell. Each cell is rendered
+// from its own hash, and neighbouring cells are blended with a smoothstep
+// across a band around each seam, so the placeholder has no visible edges.
+//
+// Pixels are written as DecodeDraw writes them, with the decoder's
+// Profile, Deterministic, Workers, Dither and HighBitDepth options. Away
+// from seams and without Dither, whose pattern spans the whole image,
+// each cell matches DecodeDraw of its hash. Detail, Fit and Approximation
+// do not apply.
+func (d *Decoder) DecodeTiles(dst draw.Image, g TileGrid, punch float64) error {
+	if err := d.Profile.check(); err != nil {
+		return err
+	}
+	if err := d.Dither.check(); err != nil {
+		return err
+	}
+	if err := g.validate(); err != nil {
+		return err
+	}
+
+	bounds := dst.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width < g.Columns || height < g.Rows {
+		return fmt.Errorf("%w: cannot decode %dx%d tiles into %dx%d image", ErrInvalidDimensions, g.Columns, g.Rows, width, height)
+	}
+
+	// Decode the colors of every tile into one buffer.
+	d.tileOffsets = growTo(d.tileOffsets, len(g.Hashes)+1)
+	d.tileSizes = growTo(d.tileSizes, len(g.Hashes))
+	total := 0
+	for k, hash := range g.Hashes {
+		numX, numY, _ := Components(hash)
+		d.tileOffsets[k] = total
+		d.tileSizes[k] = [2]int{numX, numY}
+		total += numX * numY
+	}
+	d.tileOffsets[len(g.Hashes)] = total
+	d.tileColors = growTo(d.tileColors, total)
+	for k, hash := range g.Hashes {
+		if err := decodeColors(d.tileColors[d.tileOffsets[k]:d.tileOffsets[k+1]], hash, punch, d.Profile); err != nil {
+			return err
+		}
+	}
+
+	d.spansX = growTo(d.spansX, width)
+	d.spansY = growTo(d.spansY, height)
+	d.tileCosX = growTo(d.tileCosX, width*2*maxComponents)
+	d.tileCosY = growTo(d.tileCosY, height*2*maxComponents)
+	fillTileSpans(d.spansX, d.tileCosX, g.Columns, d.Deterministic)
+	fillTileSpans(d.spansY, d.tileCosY, g.Rows, d.Deterministic)
+
+	d.writeRows(dst, 0, func(w *rowWorker, y int) {
+		d.decodeTileRow(w, y, g.Columns)
+		if !w.wide {
+			d.quantiseRow(w, y)
+		}
+	})
+	return nil
+}
+
+// decodeTileRow fills w.linear with the linear values of row y of a grid
+// with the given number of columns, from the colours and cosine tables
+// filled by DecodeTiles, which it only reads.
+func (d *Decoder) decodeTileRow(w *rowWorker, y, columns int) {
+	spanY := d.spansY[y]
+	for x := 0; x < len(w.linear)/3; x++ {
+		spanX := d.spansX[x]
+		var r, gr, b float64
+		for ky := 0; ky < 2 && spanY.cell[ky] >= 0; ky++ {
+			cosY := d.tileCosY[(y*2+ky)*maxComponents:]
+			for kx := 0; kx < 2 && spanX.cell[kx] >= 0; kx++ {
+				cosX := d.tileCosX[(x*2+kx)*maxComponents:]
+				tile := spanY.cell[ky]*columns + spanX.cell[kx]
+				numX, numY := d.tileSizes[tile][0], d.tileSizes[tile][1]
+				colors := d.tileColors[d.tileOffsets[tile]:]
+
+				var tr, tg, tb float64
+				for j := 0; j < numY; j++ {
+					basisY := cosY[j]
+					for i := 0; i < numX; i++ {
+						basis := cosX[i] * basisY
+						compColor := colors[i+j*numX]
+						tr += float64(compColor[0] * basis)
+						tg += float64(compColor[1] * basis)
+						tb += float64(compColor[2] * basis)
+					}
+				}
+
+				weight := spanX.weight[kx] * spanY.weight[ky]
+				r += float64(weight * tr)
+				gr += float64(weight * tg)
+				b += float64(weight * tb)
+			}
+		}
+		w.linear[x*3] = r
+		w.linear[x*3+1] = gr
+		w.linear[x*3+2] = b
+	}
+}
+
+// DecodeTiles decodes g into dst, blending neighbouring cells to hide seams.
+func DecodeTiles(dst draw.Image, g TileGrid, punch float64) error {
+	var d Decoder
+	return d.DecodeTiles(dst, g, punch)
+}
+
+// fillTileSpans fills spans with the cells contributing to each of the
+// len(spans) pixels along an axis split into the given number of cells,
+// and cosines with the cosines of each contributing cell's basis functions
+// at that pixel, indexed as cosines[(x*2+k)*maxComponents+i].
+//
+// A cell's basis functions are evaluated in its own coordinates, so a
+// neighbouring cell contributes the even extension of its placeholder
+// beyond its edge.
+func fillTileSpans(spans []tileSpan, cosines []float64, cells int, deterministic bool) {
+	n := len(spans)
+	start := func(c int) int { return c * n / cells }
+	size := func(c int) int { return start(c+1) - start(c) }
+
+	for c := 0; c < cells; c++ {
+		s, w := start(c), size(c)
+		for x := s; x < s+w; x++ {
+			span := tileSpan{cell: [2]int{c, -1}, weight: [2]float64{1, 0}}
+			p := float64(x) + 0.5
+			if c+1 < cells {
+				if m := tileBlend * float64(minInt(w, size(c+1))); p > float64(s+w)-m {
+					t := smoothstep((p - (float64(s+w) - m)) / (2 * m))
+					span = tileSpan{cell: [2]int{c, c + 1}, weight: [2]float64{1 - t, t}}
+				}
+			}
+			if c > 0 {
+				if m := tileBlend * float64(minInt(w, size(c-1))); p < float64(s)+m {
+					t := smoothstep((p - (float64(s) - m)) / (2 * m))
+					span = tileSpan{cell: [2]int{c, c - 1}, weight: [2]float64{t, 1 - t}}
+				}
+			}
+			spans[x] = span
+
+			for k, cell := range span.cell {
+				if cell < 0 {
+					break
+				}
+				u, cw := x-start(cell), size(cell)
+				for i := 0; i < maxComponents; i++ {
+					idx := (x*2+k)*maxComponents + i
+					if deterministic {
+						cosines[idx] = exactCos(i*absInt(u), cw)
+					} else {
+						cosines[idx] = math.Cos(math.Pi * float64(i) * float64(u) / float64(cw))
+					}
+				}
+			}
+		}
+	}
+}
+
+// smoothstep returns the smooth Hermite interpolation of t in [0, 1].
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// subImage returns the part of img within r, sharing its pixels.
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	return boundedImage{img, r}
+}
+
+// boundedImage restricts the bounds of an image that has no SubImage method.
+type boundedImage struct {
+	image.Image
+	rect image.Rectangle
+}
+
+func (b boundedImage) Bounds() image.Rectangle {
+	return b.rect
+}
@@ -0,0 +1,208 @@
+package blurhash_test
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"reflect"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestEncodeTiles(t *testing.T) {
+	img := blurhash.LoadFixture(t, "fixtures/octocat.png")
+	g, err := blurhash.EncodeTiles(3, 2, 4, 3, img)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if g.Columns != 3 || g.Rows != 2 || g.Width != 256 || g.Height != 256 || len(g.Hashes) != 6 {
+		t.Fatalf("unexpected grid: %+v", g)
+	}
+
+	// Each cell is a standard hash of its part of the image.
+	sub := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	})
+	want, err := blurhash.Encode(4, 3, sub.SubImage(image.Rect(85, 128, 170, 256)))
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+	if g.Hashes[4] != want {
+		t.Errorf("cell hash mismatch: got %q, want %q", g.Hashes[4], want)
+	}
+}
+
+func TestTileGridCompact(t *testing.T) {
+	g, err := blurhash.EncodeTiles(4, 1, 3, 3, rampImage(400, 50))
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	s, err := g.Compact()
+	if err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+	parsed, err := blurhash.ParseTileGrid(s)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, g) {
+		t.Errorf("compact round trip mismatch: got %+v, want %+v", parsed, g)
+	}
+
+	data, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	var fromJSON blurhash.TileGrid
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !reflect.DeepEqual(fromJSON, g) {
+		t.Errorf("json round trip mismatch: got %+v, want %+v", fromJSON, g)
+	}
+}
+
+func TestParseTileGridInvalid(t *testing.T) {
+	g := blurhash.TileGrid{Columns: 2, Rows: 1, Width: 10, Height: 10, Hashes: []string{testFixtures[0].hash, testFixtures[1].hash}}
+	s, err := g.Compact()
+	if err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+
+	for name, input := range map[string]string{
+		"empty":        "",
+		"header only":  s[:12],
+		"truncated":    s[:len(s)-1],
+		"extra hash":   s + testFixtures[0].hash,
+		"missing hash": s[:12+len(testFixtures[0].hash)],
+	} {
+		if _, err := blurhash.ParseTileGrid(input); !errors.Is(err, blurhash.ErrInvalidHash) {
+			t.Errorf("%s: expected ErrInvalidHash, got %v", name, err)
+		}
+	}
+
+	g.Hashes = g.Hashes[:1]
+	if _, err := g.Compact(); !errors.Is(err, blurhash.ErrInvalidHash) {
+		t.Errorf("compact with missing hash: expected ErrInvalidHash, got %v", err)
+	}
+}
+
+func TestDecodeTilesSingle(t *testing.T) {
+	g := blurhash.TileGrid{Columns: 1, Rows: 1, Width: 32, Height: 24, Hashes: []string{testFixtures[0].hash}}
+	got := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	if err := blurhash.DecodeTiles(got, g, 1); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	want := image.NewNRGBA(image.Rect(0, 0, 32, 24))
+	if err := blurhash.DecodeDraw(want, testFixtures[0].hash, 1); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if !reflect.DeepEqual(got.Pix, want.Pix) {
+		t.Error("a single tile should decode exactly as DecodeDraw")
+	}
+
+	// Decoder options apply as they do to DecodeDraw.
+	for _, d := range []blurhash.Decoder{
+		{Workers: 4},
+		{Dither: blurhash.DitherBayer},
+		{Dither: blurhash.DitherFloydSteinberg},
+		{HighBitDepth: true},
+	} {
+		for _, newImage := range []func() draw.Image{
+			func() draw.Image { return image.NewNRGBA(image.Rect(0, 0, 32, 24)) },
+			func() draw.Image { return image.NewNRGBA64(image.Rect(0, 0, 32, 24)) },
+		} {
+			got, want := newImage(), newImage()
+			if err := d.DecodeTiles(got, g, 1); err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			if err := d.DecodeDraw(want, testFixtures[0].hash, 1); err != nil {
+				t.Fatalf("decode error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%+v: a single %T tile should decode exactly as DecodeDraw", d, got)
+			}
+		}
+	}
+}
+
+func TestDecodeTilesSeams(t *testing.T) {
+	// A smooth gradient split into tiles should decode without the
+	// steps that decoding each tile independently leaves at the seams.
+	src := rampImage(400, 40)
+	g, err := blurhash.EncodeTiles(4, 1, 4, 1, src)
+	if err != nil {
+		t.Fatalf("encode error: %v", err)
+	}
+
+	blended := image.NewNRGBA(image.Rect(0, 0, 400, 40))
+	if err := blurhash.DecodeTiles(blended, g, 1); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	naive := image.NewNRGBA(image.Rect(0, 0, 400, 40))
+	for col, hash := range g.Hashes {
+		cell := naive.SubImage(image.Rect(col*100, 0, (col+1)*100, 40)).(*image.NRGBA)
+		if err := blurhash.DecodeDraw(cell, hash, 1); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+	}
+
+	seamStep := func(img *image.NRGBA) int {
+		var worst int
+		for _, x := range []int{100, 200, 300} {
+			a, b := img.NRGBAAt(x-1, 20), img.NRGBAAt(x, 20)
+			if d := absDiff(a.R, b.R) + absDiff(a.G, b.G) + absDiff(a.B, b.B); d > worst {
+				worst = d
+			}
+		}
+		return worst
+	}
+	if got, naiveStep := seamStep(blended), seamStep(naive); got >= naiveStep || got > 6 {
+		t.Errorf("blended seam step %d should be small and below the unblended step %d", got, naiveStep)
+	}
+
+	// Away from seams, cells decode exactly as their own hash.
+	if blended.NRGBAAt(150, 20) != naive.NRGBAAt(150, 20) {
+		t.Errorf("cell centre mismatch: got %v, want %v", blended.NRGBAAt(150, 20), naive.NRGBAAt(150, 20))
+	}
+}
+
+func TestDecodeTilesInvalid(t *testing.T) {
+	g := blurhash.TileGrid{Columns: 2, Rows: 2, Width: 10, Height: 10, Hashes: []string{
+		testFixtures[0].hash, testFixtures[0].hash, testFixtures[0].hash, testFixtures[0].hash,
+	}}
+	if err := blurhash.DecodeTiles(image.NewNRGBA(image.Rect(0, 0, 1, 8)), g, 1); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected ErrInvalidDimensions, got %v", err)
+	}
+	if _, err := blurhash.EncodeTiles(0, 2, 4, 3, solidImage(color.NRGBA{A: 255})); !errors.Is(err, blurhash.ErrInvalidDimensions) {
+		t.Errorf("expected ErrInvalidDimensions, got %v", err)
+	}
+
+	d := blurhash.Decoder{Dither: blurhash.DitherFloydSteinberg + 1}
+	if err := d.DecodeTiles(image.NewNRGBA(image.Rect(0, 0, 8, 8)), g, 1); !errors.Is(err, blurhash.ErrInvalidDither) {
+		t.Errorf("expected ErrInvalidDither, got %v", err)
+	}
+
+	// Other image types are decoded as DecodeDraw decodes them.
+	gray := image.NewGray(image.Rect(0, 0, 8, 8))
+	if err := blurhash.DecodeTiles(gray, g, 1); err != nil {
+		t.Errorf("decode error: %v", err)
+	}
+}
+
+// rampImage returns an image whose colour changes smoothly from left
+// to right.
+func rampImage(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			v := uint8(x * 255 / (width - 1))
+			img.SetNRGBA(x, y, color.NRGBA{v, 128, 255 - v, 255})
+		}
+	}
+	return img
+}
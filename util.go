@@ -11,6 +11,27 @@ func growTo[T any](s []T, size int) []T {
 	return s[:size]
 }
 
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
 // signSqrt returns sign(val) * sqrt(|val|)
 func signSqrt(val float64) float64 {
 	return math.Copysign(math.Sqrt(math.Abs(val)), val)
@@ -24,23 +45,6 @@ func sRGBToLinear(val int) float64 {
 // 4096 entries provides sufficient precision for 8-bit output.
 const linearToSRGBLUTSize = 4096
 
-// linearToSRGBLUT maps linear values [0,1] to sRGB [0,255].
-// Index i corresponds to linear value i/(LUTSize-1).
-var linearToSRGBLUT [linearToSRGBLUTSize]uint8
-
-func init() {
-	for i := 0; i < linearToSRGBLUTSize; i++ {
-		v := float64(i) / float64(linearToSRGBLUTSize-1)
-		var srgb float64
-		if v <= 0.0031308 {
-			srgb = v * 12.92
-		} else {
-			srgb = 1.055*math.Pow(v, 1/2.4) - 0.055
-		}
-		linearToSRGBLUT[i] = uint8(srgb*255 + 0.5)
-	}
-}
-
 func linearToSRGB(val float64) int {
 	if val <= 0 {
 		return 0
@@ -48,5 +52,5 @@ func linearToSRGB(val float64) int {
 	if val >= 1 {
 		return 255
 	}
-	return int(linearToSRGBLUT[int(val*float64(linearToSRGBLUTSize-1)+0.5)])
+	return int(linearToSRGBLUT[int(float64(val*float64(linearToSRGBLUTSize-1))+0.5)])
 }
@@ -0,0 +1,71 @@
+package blurhash
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// writesWide reports whether writeRowWide can write dst.
+func writesWide(dst draw.Image) bool {
+	switch dst.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// writeRowWide writes linear, three values per pixel, into dst starting
+// at (x, y) with 16-bit precision. dst must be one of the types accepted
+// by writesWide.
+func writeRowWide(linear []float64, dst draw.Image, x, y int) {
+	width := len(linear) / 3
+	switch img := dst.(type) {
+	case *image.RGBA64:
+		writeRow64(img.Pix[img.PixOffset(x, y):], linear)
+	case *image.NRGBA64:
+		// Opaque pixels are the same premultiplied or not.
+		writeRow64(img.Pix[img.PixOffset(x, y):], linear)
+	case *image.Gray16:
+		pix := img.Pix[img.PixOffset(x, y):]
+		for i := 0; i < width; i++ {
+			r := uint32(linearToSRGB16(linear[i*3]))
+			g := uint32(linearToSRGB16(linear[i*3+1]))
+			b := uint32(linearToSRGB16(linear[i*3+2]))
+			// As color.Gray16Model converts an opaque colour.
+			v := (19595*r + 38470*g + 7471*b + 1<<15) >> 16
+			pix[i*2] = uint8(v >> 8)
+			pix[i*2+1] = uint8(v)
+		}
+	}
+}
+
+// writeRow64 writes linear into pix as opaque big-endian 16-bit RGBA.
+func writeRow64(pix []uint8, linear []float64) {
+	for i := 0; i < len(linear)/3; i++ {
+		for c := 0; c < 3; c++ {
+			v := linearToSRGB16(linear[i*3+c])
+			pix[i*8+c*2] = uint8(v >> 8)
+			pix[i*8+c*2+1] = uint8(v)
+		}
+		pix[i*8+6], pix[i*8+7] = 0xff, 0xff
+	}
+}
+
+// linearToSRGB16 converts a linear value to a 16-bit sRGB value with the
+// exact transfer function.
+func linearToSRGB16(val float64) uint16 {
+	if val <= 0 {
+		return 0
+	}
+	if val >= 1 {
+		return 0xffff
+	}
+	var srgb float64
+	if val <= 0.0031308 {
+		srgb = float64(val * 12.92)
+	} else {
+		srgb = float64(1.055*math.Pow(val, 1/2.4)) - 0.055
+	}
+	return uint16(float64(srgb*0xffff) + 0.5)
+}
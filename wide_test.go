@@ -0,0 +1,143 @@
+package blurhash_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+func TestHighBitDepth(t *testing.T) {
+	for _, test := range testFixtures {
+		if test.hash == "" {
+			continue
+		}
+
+		narrow := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		if err := blurhash.DecodeDraw(narrow, test.hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+		dec := blurhash.Decoder{HighBitDepth: true}
+		wide := image.NewNRGBA64(image.Rect(0, 0, 32, 32))
+		if err := dec.DecodeDraw(wide, test.hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+
+		// Dropping the extra precision gives the 8-bit output, give or
+		// take the rounding of the 8-bit lookup table.
+		for i := 0; i < len(narrow.Pix); i++ {
+			v16 := int(wide.Pix[i*2])<<8 | int(wide.Pix[i*2+1])
+			if d := (v16+128)/257 - int(narrow.Pix[i]); d < -1 || d > 1 {
+				t.Fatalf("%s: channel %d is %d at 16 bits but %d at 8 bits", test.hash, i, v16, narrow.Pix[i])
+			}
+		}
+	}
+}
+
+func TestHighBitDepthGradient(t *testing.T) {
+	// A shallow horizontal ramp bands badly at 8 bits.
+	src := image.NewNRGBA(image.Rect(0, 0, 64, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 64; x++ {
+			v := uint8(100 + x*12/63)
+			src.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	hash, err := blurhash.Encode(2, 1, src)
+	if err != nil {
+		t.Fatalf("error encoding: %v", err)
+	}
+
+	const width = 1024
+	narrow := image.NewRGBA64(image.Rect(0, 0, width, 1))
+	if err := blurhash.DecodeDraw(narrow, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+	dec := blurhash.Decoder{HighBitDepth: true}
+	wide := image.NewRGBA64(image.Rect(0, 0, width, 1))
+	if err := dec.DecodeDraw(wide, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	levels := func(img *image.RGBA64) int {
+		seen := make(map[uint16]bool)
+		for x := 0; x < width; x++ {
+			seen[img.RGBA64At(x, 0).R] = true
+		}
+		return len(seen)
+	}
+	if n, n8 := levels(wide), levels(narrow); n < 20*n8 {
+		t.Errorf("16-bit gradient has %d levels, want far more than the %d of 8-bit output", n, n8)
+	}
+	for x := 1; x < width; x++ {
+		if wide.RGBA64At(x, 0).R < wide.RGBA64At(x-1, 0).R {
+			t.Fatalf("16-bit gradient is not monotonic at x=%d", x)
+		}
+	}
+}
+
+func TestHighBitDepthDestinations(t *testing.T) {
+	hash := testFixtures[0].hash
+	r := image.Rect(-3, 2, 29, 27)
+	dec := blurhash.Decoder{HighBitDepth: true}
+	want := image.NewRGBA64(r)
+	if err := dec.DecodeDraw(want, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	nrgba := image.NewNRGBA64(r)
+	gray := image.NewGray16(r)
+	parallel := image.NewRGBA64(r)
+	for _, dst := range []draw.Image{nrgba, gray} {
+		if err := dec.DecodeDraw(dst, hash, 1); err != nil {
+			t.Fatalf("error decoding: %v", err)
+		}
+	}
+	workers := blurhash.Decoder{HighBitDepth: true, Workers: 4}
+	if err := workers.DecodeDraw(parallel, hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			w := want.RGBA64At(x, y)
+			if got := nrgba.NRGBA64At(x, y); got != (color.NRGBA64{w.R, w.G, w.B, w.A}) {
+				t.Fatalf("NRGBA64 pixel (%d, %d) = %v, want %v", x, y, got, w)
+			}
+			if got, wg := gray.Gray16At(x, y), color.Gray16Model.Convert(w); got != wg {
+				t.Fatalf("Gray16 pixel (%d, %d) = %v, want %v", x, y, got, wg)
+			}
+			if got := parallel.RGBA64At(x, y); got != w {
+				t.Fatalf("parallel pixel (%d, %d) = %v, want %v", x, y, got, w)
+			}
+		}
+	}
+}
+
+func TestHighBitDepthPNG(t *testing.T) {
+	dec := blurhash.Decoder{HighBitDepth: true}
+	img := image.NewRGBA64(image.Rect(0, 0, 48, 32))
+	if err := dec.DecodeDraw(img, testFixtures[1].hash, 1); err != nil {
+		t.Fatalf("error decoding: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("error encoding PNG: %v", err)
+	}
+	got, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("error decoding PNG: %v", err)
+	}
+	rgba64, ok := got.(*image.RGBA64)
+	if !ok {
+		t.Fatalf("expected a 16-bit PNG, decoded as %T", got)
+	}
+	if !bytes.Equal(rgba64.Pix, img.Pix) {
+		t.Error("16-bit PNG does not round trip")
+	}
+}
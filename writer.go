@@ -0,0 +1,225 @@
+package blurhash
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// writeRow writes w.row, three sRGB bytes per pixel, into dst starting
+// at (x, y). Every pixel is written opaque, and matches what dst.Set with
+// the equivalent color.NRGBA would store.
+func (d *Decoder) writeRow(w *rowWorker, dst draw.Image, x, y int) {
+	if w.wide {
+		writeRowWide(w.linear, dst, x, y)
+		return
+	}
+
+	row := w.row
+	width := len(row) / 3
+	switch img := dst.(type) {
+	case *image.NRGBA:
+		writeRow8(img.Pix[img.PixOffset(x, y):], row)
+	case *image.RGBA:
+		// Opaque pixels are the same premultiplied or not.
+		writeRow8(img.Pix[img.PixOffset(x, y):], row)
+	case *image.NRGBA64:
+		writeRow16(img.Pix[img.PixOffset(x, y):], row)
+	case *image.RGBA64:
+		writeRow16(img.Pix[img.PixOffset(x, y):], row)
+	case *image.Gray:
+		pix := img.Pix[img.PixOffset(x, y):]
+		for i := 0; i < width; i++ {
+			pix[i] = uint8(luma(row[i*3], row[i*3+1], row[i*3+2]) >> 24)
+		}
+	case *image.Gray16:
+		pix := img.Pix[img.PixOffset(x, y):]
+		for i := 0; i < width; i++ {
+			v := luma(row[i*3], row[i*3+1], row[i*3+2]) >> 16
+			pix[i*2] = uint8(v >> 8)
+			pix[i*2+1] = uint8(v)
+		}
+	case *image.CMYK:
+		pix := img.Pix[img.PixOffset(x, y):]
+		for i := 0; i < width; i++ {
+			pix[i*4], pix[i*4+1], pix[i*4+2], pix[i*4+3] = color.RGBToCMYK(row[i*3], row[i*3+1], row[i*3+2])
+		}
+	case *image.Paletted:
+		pix := img.Pix[img.PixOffset(x, y):]
+		for i := 0; i < width; i++ {
+			pix[i] = d.paletteIndex(w, row[i*3], row[i*3+1], row[i*3+2])
+		}
+	case draw.RGBA64Image:
+		// Avoids boxing a color.Color for every pixel.
+		for i := 0; i < width; i++ {
+			img.SetRGBA64(x+i, y, color.RGBA64{
+				uint16(row[i*3]) * 0x101,
+				uint16(row[i*3+1]) * 0x101,
+				uint16(row[i*3+2]) * 0x101,
+				0xffff,
+			})
+		}
+	default:
+		for i := 0; i < width; i++ {
+			dst.Set(x+i, y, color.NRGBA{row[i*3], row[i*3+1], row[i*3+2], 255})
+		}
+	}
+}
+
+// writesDirectly reports whether writeRow writes the pixels of dst
+// itself rather than through its methods.
+func writesDirectly(dst draw.Image) bool {
+	switch dst.(type) {
+	case *image.NRGBA, *image.RGBA, *image.NRGBA64, *image.RGBA64,
+		*image.Gray, *image.Gray16, *image.CMYK, *image.Paletted:
+		return true
+	}
+	return false
+}
+
+// writeRow8 writes row into pix as opaque 8-bit RGBA.
+func writeRow8(pix, row []uint8) {
+	for i := 0; i < len(row)/3; i++ {
+		pix[i*4] = row[i*3]
+		pix[i*4+1] = row[i*3+1]
+		pix[i*4+2] = row[i*3+2]
+		pix[i*4+3] = 255
+	}
+}
+
+// writeRow16 writes row into pix as opaque big-endian 16-bit RGBA.
+func writeRow16(pix, row []uint8) {
+	for i := 0; i < len(row)/3; i++ {
+		pix[i*8], pix[i*8+1] = row[i*3], row[i*3]
+		pix[i*8+2], pix[i*8+3] = row[i*3+1], row[i*3+1]
+		pix[i*8+4], pix[i*8+5] = row[i*3+2], row[i*3+2]
+		pix[i*8+6], pix[i*8+7] = 255, 255
+	}
+}
+
+// luma returns the luminance of an sRGB colour scaled by 1<<24, rounded
+// as color.GrayModel and color.Gray16Model round it.
+func luma(r, g, b uint8) uint32 {
+	return 19595*uint32(r)*0x101 + 38470*uint32(g)*0x101 + 7471*uint32(b)*0x101 + 1<<15
+}
+
+// paletteIndex returns the index of the palette colour nearest to the
+// given opaque colour, as color.Palette.Index finds it, from the palette
+// loaded by loadPalette. Blurhash renders are smooth, so results are
+// cached in w for the duration of a decode.
+func (d *Decoder) paletteIndex(w *rowWorker, r, g, b uint8) uint8 {
+	key := uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+	if i, ok := w.paletteCache[key]; ok {
+		return i
+	}
+
+	cr, cg, cb := uint32(r)*0x101, uint32(g)*0x101, uint32(b)*0x101
+	best, bestSum := 0, uint32(1<<32-1)
+	for i, v := range d.palette {
+		sum := sqDiff(cr, v[0]) + sqDiff(cg, v[1]) + sqDiff(cb, v[2]) + sqDiff(0xffff, v[3])
+		if sum < bestSum {
+			best, bestSum = i, sum
+			if sum == 0 {
+				break
+			}
+		}
+	}
+	w.paletteCache[key] = uint8(best)
+	return uint8(best)
+}
+
+// sqDiff returns the squared difference of x and y, shifted right by 2,
+// as color.Palette.Index computes it.
+func sqDiff(x, y uint32) uint32 {
+	v := x - y
+	return (v * v) >> 2
+}
+
+// loadPalette prepares paletteIndex for p. Workers empty their caches,
+// whose entries are only valid for a single palette, when reset.
+func (d *Decoder) loadPalette(p color.Palette) {
+	d.palette = growTo(d.palette, len(p))
+	for i, c := range p {
+		r, g, b, a := c.RGBA()
+		d.palette[i] = [4]uint32{r, g, b, a}
+	}
+}
+
+// DecodeYCbCr decodes a blurhash into an existing YCbCr image, such as
+// one about to be passed to a JPEG or video encoder. Luma is written for
+// every pixel, and each chroma sample is computed from the mean colour
+// of the pixels it covers under the image's subsample ratio.
+func (d *Decoder) DecodeYCbCr(dst *image.YCbCr, hash string, punch float64) error {
+	bounds := dst.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	numX, numY, err := d.prepare(hash, width, height, punch)
+	if err != nil || bounds.Empty() {
+		return err
+	}
+
+	// Bands of rows must start on a chroma row, as each chroma sample
+	// is accumulated by a single worker.
+	chromaStart := func(y int) bool {
+		py := bounds.Min.Y + y
+		return dst.COffset(bounds.Min.X, py) != dst.COffset(bounds.Min.X, py-1)
+	}
+	chromaWidth := dst.COffset(bounds.Max.X-1, bounds.Min.Y) - dst.COffset(bounds.Min.X, bounds.Min.Y) + 1
+	workers := d.Workers
+	if d.Dither.sequential() {
+		workers = 1
+	}
+	d.parallelRows(workers, height, chromaStart, func(w *rowWorker, y0, y1 int) {
+		w.reset(width, numX, d.Dither, false, false)
+		w.chroma = growTo(w.chroma, chromaWidth)
+		for y := y0; y < y1; y++ {
+			d.decodeYCbCrRow(w, dst, y, width, height, numX, numY, y == y0)
+		}
+	})
+
+	return nil
+}
+
+// decodeYCbCrRow decodes row y into dst, accumulating its colours into
+// w.chroma and writing the chroma samples once their last row is done.
+// first is set for the first row decoded by w.
+func (d *Decoder) decodeYCbCrRow(w *rowWorker, dst *image.YCbCr, y, width, height, numX, numY int, first bool) {
+	bounds := dst.Bounds()
+	py := bounds.Min.Y + y
+	ci := dst.COffset(bounds.Min.X, py)
+	if first || ci != dst.COffset(bounds.Min.X, py-1) {
+		for i := range w.chroma {
+			w.chroma[i] = [4]int{}
+		}
+	}
+
+	d.decodeRow(w, y, width, height, numX, numY)
+	yi := dst.YOffset(bounds.Min.X, py)
+	for x := 0; x < width; x++ {
+		r, g, b := w.row[x*3], w.row[x*3+1], w.row[x*3+2]
+		dst.Y[yi+x], _, _ = color.RGBToYCbCr(r, g, b)
+
+		sum := &w.chroma[dst.COffset(bounds.Min.X+x, py)-ci]
+		sum[0] += int(r)
+		sum[1] += int(g)
+		sum[2] += int(b)
+		sum[3]++
+	}
+
+	// Write the chroma row once its last image row is accumulated.
+	if y == height-1 || ci != dst.COffset(bounds.Min.X, py+1) {
+		for i, sum := range w.chroma {
+			n := sum[3]
+			_, dst.Cb[ci+i], dst.Cr[ci+i] = color.RGBToYCbCr(
+				uint8((sum[0]+n/2)/n),
+				uint8((sum[1]+n/2)/n),
+				uint8((sum[2]+n/2)/n),
+			)
+		}
+	}
+}
+
+// DecodeYCbCr decodes the given hash into the given YCbCr image.
+func DecodeYCbCr(dst *image.YCbCr, hash string, punch float64) error {
+	var d Decoder
+	return d.DecodeYCbCr(dst, hash, punch)
+}
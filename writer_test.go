@@ -0,0 +1,171 @@
+package blurhash_test
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"testing"
+
+	"github.com/bbrks/go-blurhash"
+)
+
+// setOnly hides every method of an image beyond draw.Image, so decoding
+// into it takes the generic Set path.
+type setOnly struct {
+	draw.Image
+}
+
+// rgba64Only hides every method of an image beyond draw.RGBA64Image.
+type rgba64Only struct {
+	draw.RGBA64Image
+}
+
+func writerDestinations() map[string]func() draw.Image {
+	r := image.Rect(3, -2, 40, 27)
+	return map[string]func() draw.Image{
+		"NRGBA":    func() draw.Image { return image.NewNRGBA(r) },
+		"RGBA":     func() draw.Image { return image.NewRGBA(r) },
+		"NRGBA64":  func() draw.Image { return image.NewNRGBA64(r) },
+		"RGBA64":   func() draw.Image { return image.NewRGBA64(r) },
+		"Gray":     func() draw.Image { return image.NewGray(r) },
+		"Gray16":   func() draw.Image { return image.NewGray16(r) },
+		"CMYK":     func() draw.Image { return image.NewCMYK(r) },
+		"Paletted": func() draw.Image { return image.NewPaletted(r, palette.Plan9) },
+		"WebSafe":  func() draw.Image { return image.NewPaletted(r, palette.WebSafe) },
+		"SubImage": func() draw.Image {
+			return image.NewRGBA64(image.Rect(0, 0, 64, 64)).SubImage(r).(draw.Image)
+		},
+	}
+}
+
+func TestDecodeDrawWriters(t *testing.T) {
+	for name, newImage := range writerDestinations() {
+		t.Run(name, func(t *testing.T) {
+			for _, test := range testFixtures {
+				if test.hash == "" {
+					continue
+				}
+
+				want := newImage()
+				if err := blurhash.DecodeDraw(setOnly{want}, test.hash, 1); err != nil {
+					t.Fatalf("error decoding: %v", err)
+				}
+
+				dsts := []draw.Image{newImage()}
+				if dst, ok := newImage().(draw.RGBA64Image); ok {
+					dsts = append(dsts, rgba64Only{dst})
+				}
+				for _, dst := range dsts {
+					if err := blurhash.DecodeDraw(dst, test.hash, 1); err != nil {
+						t.Fatalf("error decoding: %v", err)
+					}
+					b := want.Bounds()
+					for y := b.Min.Y; y < b.Max.Y; y++ {
+						for x := b.Min.X; x < b.Max.X; x++ {
+							if got, w := dst.At(x, y), want.At(x, y); got != w {
+								t.Fatalf("%T: pixel (%d, %d) = %v, want %v", dst, x, y, got, w)
+							}
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeYCbCr(t *testing.T) {
+	ratios := []image.YCbCrSubsampleRatio{
+		image.YCbCrSubsampleRatio444,
+		image.YCbCrSubsampleRatio422,
+		image.YCbCrSubsampleRatio420,
+		image.YCbCrSubsampleRatio440,
+		image.YCbCrSubsampleRatio411,
+		image.YCbCrSubsampleRatio410,
+	}
+	// Odd sizes and origins leave partial chroma blocks on every edge.
+	rects := []image.Rectangle{
+		image.Rect(0, 0, 32, 32),
+		image.Rect(1, -3, 30, 22),
+	}
+
+	hash := testFixtures[0].hash
+	for _, ratio := range ratios {
+		for _, r := range rects {
+			want := image.NewNRGBA(r)
+			if err := blurhash.DecodeDraw(want, hash, 1); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+			dst := image.NewYCbCr(r, ratio)
+			if err := blurhash.DecodeYCbCr(dst, hash, 1); err != nil {
+				t.Fatalf("error decoding: %v", err)
+			}
+
+			// Each chroma sample must lie within the range of the pixels
+			// it covers, give or take rounding.
+			lo := make(map[int][2]uint8)
+			hi := make(map[int][2]uint8)
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					w := want.NRGBAAt(x, y)
+					wy, wcb, wcr := color.RGBToYCbCr(w.R, w.G, w.B)
+					if got := dst.YCbCrAt(x, y).Y; got != wy {
+						t.Fatalf("%v %v: luma at (%d, %d) = %d, want %d", ratio, r, x, y, got, wy)
+					}
+
+					ci := dst.COffset(x, y)
+					l, ok := lo[ci]
+					h := hi[ci]
+					if !ok {
+						l, h = [2]uint8{255, 255}, [2]uint8{}
+					}
+					lo[ci] = [2]uint8{minUint8(l[0], wcb), minUint8(l[1], wcr)}
+					hi[ci] = [2]uint8{maxUint8(h[0], wcb), maxUint8(h[1], wcr)}
+				}
+			}
+			for ci, l := range lo {
+				h := hi[ci]
+				cb, cr := dst.Cb[ci], dst.Cr[ci]
+				if int(cb)+1 < int(l[0]) || int(cb) > int(h[0])+1 || int(cr)+1 < int(l[1]) || int(cr) > int(h[1])+1 {
+					t.Fatalf("%v %v: chroma sample %d = (%d, %d), want within (%d-%d, %d-%d)", ratio, r, ci, cb, cr, l[0], h[0], l[1], h[1])
+				}
+			}
+		}
+	}
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxUint8(a, b uint8) uint8 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func BenchmarkDecodeDrawWriters(b *testing.B) {
+	hash := testFixtures[0].hash
+	for name, newImage := range writerDestinations() {
+		b.Run(name, func(b *testing.B) {
+			dec := blurhash.NewDecoder()
+			dst := newImage()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = dec.DecodeDraw(dst, hash, 1)
+			}
+		})
+	}
+	b.Run("YCbCr", func(b *testing.B) {
+		dec := blurhash.NewDecoder()
+		dst := image.NewYCbCr(image.Rect(0, 0, 37, 29), image.YCbCrSubsampleRatio420)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = dec.DecodeYCbCr(dst, hash, 1)
+		}
+	})
+}
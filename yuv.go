@@ -0,0 +1,179 @@
+package blurhash
+
+import "fmt"
+
+// BufferFormat describes the pixels of a raw buffer read by EncodeBuffer.
+//
+// The zero values of the YUV fields match the defaults of Y4MReader:
+// BT.601 coefficients, limited range samples and chroma sited midway
+// between luma samples.
+type BufferFormat struct {
+	// Pixel is the memory layout of the pixels.
+	Pixel PixelFormat
+
+	// ChromaSiting is the position of chroma samples relative to luma
+	// samples in planar YUV formats.
+	ChromaSiting ChromaSiting
+
+	// Matrix selects the coefficients converting planar YUV formats to
+	// RGB.
+	Matrix YCbCrMatrix
+
+	// FullRange reports that YUV samples span 0-255, as in JPEG, rather
+	// than the limited range of 16-235 for luma and 16-240 for chroma
+	// usual in video.
+	FullRange bool
+}
+
+// check returns an error if f has an unknown siting or matrix. The pixel
+// format is checked by checkBuffer.
+func (f BufferFormat) check() error {
+	if f.ChromaSiting < ChromaCentre || f.ChromaSiting > ChromaTopLeft {
+		return fmt.Errorf("%w: unknown chroma siting %d", ErrInvalidPixelFormat, int(f.ChromaSiting))
+	}
+	if f.Matrix < MatrixBT601 || f.Matrix > MatrixBT709 {
+		return fmt.Errorf("%w: unknown YCbCr matrix %d", ErrInvalidPixelFormat, int(f.Matrix))
+	}
+	return nil
+}
+
+// ChromaSiting is the position of subsampled chroma samples relative to
+// the luma samples they cover.
+type ChromaSiting int
+
+const (
+	// ChromaCentre sites chroma midway between luma samples in both
+	// directions, as in JPEG and MPEG-1.
+	ChromaCentre ChromaSiting = iota
+
+	// ChromaLeft sites chroma with the left luma sample of each pair
+	// and midway between rows, as in MPEG-2 and by default in H.264.
+	ChromaLeft
+
+	// ChromaTopLeft sites chroma with the top left luma sample of each
+	// block, as in BT.2020 and H.265 type 2 siting.
+	ChromaTopLeft
+)
+
+// YCbCrMatrix selects the coefficients converting YCbCr to RGB.
+type YCbCrMatrix int
+
+const (
+	// MatrixBT601 uses the coefficients of ITU-R BT.601, as in standard
+	// definition video, JPEG and image.YCbCr.
+	MatrixBT601 YCbCrMatrix = iota
+
+	// MatrixBT709 uses the coefficients of ITU-R BT.709, as in high
+	// definition video.
+	MatrixBT709
+)
+
+// i420Planes returns the offsets of the Cb and Cr planes of an I420
+// buffer whose luma rows are stride bytes apart, and the stride of the
+// chroma planes.
+func i420Planes(height, stride int) (cb, cr, cStride int) {
+	cStride = (stride + 1) / 2
+	cb = stride * height
+	cr = cb + cStride*((height+1)/2)
+	return cb, cr, cStride
+}
+
+// convertI420 converts the I420 pixels of buf to 8-bit RGB triples in
+// e.rgb, interpolating chroma bilinearly from where it is sited.
+func (e *Encoder) convertI420(buf []byte, format BufferFormat, width, height, stride int) {
+	cbPlane, crPlane, cStride := i420Planes(height, stride)
+	cw, ch := (width+1)/2, (height+1)/2
+	e.tapsX = growTo(e.tapsX, width)
+	e.tapsY = growTo(e.tapsY, height)
+	fillChromaTaps(e.tapsX, cw, format.ChromaSiting != ChromaCentre)
+	fillChromaTaps(e.tapsY, ch, format.ChromaSiting == ChromaTopLeft)
+	e.cbRow = growTo(e.cbRow, cw)
+	e.crRow = growTo(e.crRow, cw)
+	e.rgb = growTo(e.rgb, width*height*3)
+	m := format.matrix()
+
+	for y := 0; y < height; y++ {
+		// Interpolate vertically into e.cbRow and e.crRow, in quarters.
+		ty := e.tapsY[y]
+		cb0, cb1 := buf[cbPlane+ty.i0*cStride:], buf[cbPlane+ty.i1*cStride:]
+		cr0, cr1 := buf[crPlane+ty.i0*cStride:], buf[crPlane+ty.i1*cStride:]
+		for i := 0; i < cw; i++ {
+			e.cbRow[i] = ty.w0*int(cb0[i]) + (4-ty.w0)*int(cb1[i])
+			e.crRow[i] = ty.w0*int(cr0[i]) + (4-ty.w0)*int(cr1[i])
+		}
+
+		luma := buf[y*stride:]
+		rgb := e.rgb[y*width*3:]
+		for x := 0; x < width; x++ {
+			tx := e.tapsX[x]
+			cb := tx.w0*e.cbRow[tx.i0] + (4-tx.w0)*e.cbRow[tx.i1]
+			cr := tx.w0*e.crRow[tx.i0] + (4-tx.w0)*e.crRow[tx.i1]
+			m.convert(rgb[x*3:x*3+3], luma[x], cb, cr)
+		}
+	}
+}
+
+// chromaTap interpolates between two chroma samples at a luma position.
+type chromaTap struct {
+	i0, i1 int
+	w0     int // the weight of i0 in quarters; i1 has the rest
+}
+
+// fillChromaTaps fills taps with the bilinear interpolation of a row or
+// column of the given number of chroma samples at each luma position.
+// Chroma sample i lies at luma position 2i if cosited, and 2i+0.5
+// otherwise. Positions beyond the outermost samples take their value.
+func fillChromaTaps(taps []chromaTap, samples int, cosited bool) {
+	for p := range taps {
+		// The chroma coordinate of p in quarters, which is never below -1.
+		q := 2 * p
+		if !cosited {
+			q--
+		}
+		i0 := (q+4)/4 - 1
+		taps[p] = chromaTap{
+			i0: minInt(maxInt(i0, 0), samples-1),
+			i1: minInt(i0+1, samples-1),
+			w0: 4 - (q - i0*4),
+		}
+	}
+}
+
+// yuvMatrix converts YCbCr samples to RGB.
+type yuvMatrix struct {
+	yOffset, yScale, cScale float64
+	crR, cbG, crG, cbB      float64
+}
+
+// matrix returns the conversion of YCbCr samples in format f to RGB.
+func (f BufferFormat) matrix() yuvMatrix {
+	kr, kb := 0.299, 0.114
+	if f.Matrix == MatrixBT709 {
+		kr, kb = 0.2126, 0.0722
+	}
+	kg := 1 - kr - kb
+	m := yuvMatrix{
+		yScale: 1,
+		cScale: 1,
+		crR:    2 * (1 - kr),
+		cbG:    -2 * kb * (1 - kb) / kg,
+		crG:    -2 * kr * (1 - kr) / kg,
+		cbB:    2 * (1 - kb),
+	}
+	if !f.FullRange {
+		m.yOffset, m.yScale, m.cScale = 16, 255.0/219, 255.0/224
+	}
+	return m
+}
+
+// convert writes the 8-bit RGB of a luma sample and two chroma samples
+// in sixteenths to dst.
+func (m *yuvMatrix) convert(dst []uint8, y uint8, cb16, cr16 int) {
+	// Explicit conversions prevent fused multiply-adds.
+	l := float64(float64(y)-m.yOffset) * m.yScale
+	cb := float64(float64(cb16-128*16)/16) * m.cScale
+	cr := float64(float64(cr16-128*16)/16) * m.cScale
+	dst[0] = clampUint8(l + float64(m.crR*cr) + 0.5)
+	dst[1] = clampUint8(l + float64(m.cbG*cb) + float64(m.crG*cr) + 0.5)
+	dst[2] = clampUint8(l + float64(m.cbB*cb) + 0.5)
+}
@@ -0,0 +1,63 @@
+package blurhash
+
+import "testing"
+
+func TestFillChromaTaps(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples int
+		cosited bool
+		want    []chromaTap
+	}{
+		{
+			// Samples at 0.5, 2.5 and 4.5.
+			name: "interstitial", samples: 3, cosited: false,
+			want: []chromaTap{{0, 0, 1}, {0, 1, 3}, {0, 1, 1}, {1, 2, 3}, {1, 2, 1}, {2, 2, 3}},
+		},
+		{
+			// Samples at 0, 2 and 4.
+			name: "cosited", samples: 3, cosited: true,
+			want: []chromaTap{{0, 1, 4}, {0, 1, 2}, {1, 2, 4}, {1, 2, 2}, {2, 2, 4}, {2, 2, 2}},
+		},
+		{
+			name: "single sample", samples: 1, cosited: false,
+			want: []chromaTap{{0, 0, 1}, {0, 0, 3}},
+		},
+	}
+	for _, test := range tests {
+		taps := make([]chromaTap, len(test.want))
+		fillChromaTaps(taps, test.samples, test.cosited)
+		for p, tap := range taps {
+			if tap != test.want[p] {
+				t.Errorf("%s: position %d has tap %+v, want %+v", test.name, p, tap, test.want[p])
+			}
+		}
+	}
+}
+
+func TestYUVMatrix(t *testing.T) {
+	// Black, white and saturated red, whose samples are rounded so may
+	// convert to within one level of the exact colour.
+	tests := []struct {
+		format    BufferFormat
+		y, cb, cr uint8
+		want      [3]uint8
+	}{
+		{BufferFormat{}, 16, 128, 128, [3]uint8{0, 0, 0}},
+		{BufferFormat{}, 235, 128, 128, [3]uint8{255, 255, 255}},
+		{BufferFormat{}, 81, 90, 240, [3]uint8{255, 0, 0}},
+		{BufferFormat{Matrix: MatrixBT709}, 63, 102, 240, [3]uint8{255, 0, 0}},
+		{BufferFormat{FullRange: true}, 76, 85, 255, [3]uint8{255, 0, 0}},
+	}
+	for _, test := range tests {
+		m := test.format.matrix()
+		var got [3]uint8
+		m.convert(got[:], test.y, int(test.cb)*16, int(test.cr)*16)
+		for c := range got {
+			if d := int(got[c]) - int(test.want[c]); d < -1 || d > 1 {
+				t.Errorf("%+v: YCbCr(%d, %d, %d) = %v, want %v", test.format, test.y, test.cb, test.cr, got, test.want)
+				break
+			}
+		}
+	}
+}